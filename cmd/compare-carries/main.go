@@ -0,0 +1,19 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/comparecarries"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+)
+
+func main() {
+	rand.Seed(time.Now().UTC().UnixNano())
+
+	command := comparecarries.NewCmdCompareCarries(genericclioptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr})
+	if err := command.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,194 @@
+package applypatchseries
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type ApplyPatchSeriesOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+	PatchDir string
+
+	Repo        string // like kubernetes, api, apimachinery, etc
+	ForkOwner   string // like origin
+	ForkVersion string // like 4.2
+	KubeVersion string // like 1.15.0
+
+	Push bool
+}
+
+func NewApplyPatchSeriesOptions(streams genericclioptions.IOStreams) *ApplyPatchSeriesOptions {
+	return &ApplyPatchSeriesOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:      "kubernetes",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdApplyPatchSeries applies a directory of patches and its series file onto a fork branch.
+func NewCmdApplyPatchSeries(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewApplyPatchSeriesOptions(streams)
+	cmd := &cobra.Command{
+		Use: "apply-patch-series --kube-home=/path/to/k8s.io --patch-dir=/path/to/patches --repo=kubernetes --fork-version=4.3 --kube-version=1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+The patches listed in the series file of --patch-dir, like make-pick-list --patch-dir writes, are applied in order
+with git am --3way --keep onto the local fork branch, starting from the fork branch on the openshift remote, so
+subjects like "[release-4.2] ..." are kept exactly.  The first patch that doesn't apply stops the series and leaves
+the branch with the patches before it.  A patch the series file notes as squashing into another, like make-pick-list
+writes for squash decisions, is folded into the commit of the patch it squashes into, keeping that commit's message.
+That patch, or another patch folded into it, must be right before the squash, otherwise the series is refused before
+anything is applied.  With --push, a fully applied series is pushed to the fork branch on the openshift remote, which
+is only allowed as a fast-forward.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.PatchDir, "patch-dir", o.PatchDir, "directory with the patches and their series file")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.14.1")
+	cmd.Flags().BoolVar(&o.Push, "push", o.Push, "push the fork branch to the openshift remote once every patch applied")
+
+	return cmd
+}
+
+func (o *ApplyPatchSeriesOptions) Run() error {
+	if len(o.PatchDir) == 0 {
+		return fmt.Errorf("must have patch-dir")
+	}
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+
+	patches, err := kubefork.ReadPatchSeries(o.PatchDir)
+	if err != nil {
+		return err
+	}
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		if _, err := kubefork.FindOpenShiftBranch(forkBranch.BranchName(), repo); err != nil {
+			return err
+		}
+
+		if err := applyPatches(o.Streams.Indent(), currInfo, forkBranch, patches); err != nil {
+			return err
+		}
+		if !o.Push {
+			return nil
+		}
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, pushing %q to %q\n", currInfo.UpstreamName, forkBranch.BranchName(), currInfo.Openshift.Name)
+		return kubefork.RunCmd(o.Streams.Indent(), currInfo.Path, "git", "push", currInfo.Openshift.Name, forkBranch.BranchName())
+	}
+
+	return fmt.Errorf("unknown repo %q", o.Repo)
+}
+
+// checkSquashes makes sure that every squash comes right after the patch it squashes into, or after other patches
+// folded into that patch, so that it can be folded into HEAD.
+func checkSquashes(patches []kubefork.SeriesPatch) error {
+	// foldedInto is the commit of the patch each patch ended up in
+	foldedInto := map[string]string{}
+	head := ""
+	for i, patch := range patches {
+		if len(patch.SquashInto) == 0 {
+			head = patch.Commit
+			foldedInto[patch.Commit] = patch.Commit
+			continue
+		}
+		if len(head) == 0 || foldedInto[patch.SquashInto] != head {
+			return fmt.Errorf("patch %d of %d, %v, squashes into %s, which is not the patch before it", i+1, len(patches), patch.Path, patch.SquashInto)
+		}
+		foldedInto[patch.Commit] = head
+	}
+	return nil
+}
+
+// applyPatches resets the local fork branch to the openshift remote and applies the patches onto it.
+func applyPatches(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, patches []kubefork.SeriesPatch) error {
+	if err := checkSquashes(patches); err != nil {
+		return err
+	}
+
+	branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, applying %d patches onto %q\n", currInfo.UpstreamName, len(patches), forkBranch.BranchName())
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "checkout", "-B", forkBranch.BranchName(), branchRef); err != nil {
+		return err
+	}
+	// the built-in reset and cleanoptions don't seem to work.  other weird behavior is mentioned in issues
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "reset", "--hard", branchRef); err != nil {
+		return err
+	}
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "clean", "-fd"); err != nil {
+		return err
+	}
+
+	for i, patch := range patches {
+		absPatch, err := filepath.Abs(patch.Path)
+		if err != nil {
+			return err
+		}
+		// --keep matches format-patch --keep-subject, so subjects like "[release-4.2] ..." survive
+		if err := kubefork.RunCmd(streams, currInfo.Path, "git", "am", "--3way", "--keep", "--keep-cr", absPatch); err != nil {
+			kubefork.RunCmd(streams, currInfo.Path, "git", "am", "--abort")
+			return fmt.Errorf("patch %d of %d, %v, does not apply, %q has the %d before it: %v", i+1, len(patches), patch.Path, forkBranch.BranchName(), i, err)
+		}
+		if len(patch.SquashInto) == 0 {
+			continue
+		}
+		if err := kubefork.RunCmd(streams, currInfo.Path, "git", "reset", "--soft", "HEAD~1"); err != nil {
+			return err
+		}
+		if err := kubefork.RunCmd(streams, currInfo.Path, "git", "commit", "--quiet", "--amend", "--no-edit"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
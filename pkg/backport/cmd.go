@@ -0,0 +1,334 @@
+package backport
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubecommitindex"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type BackportOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Commits string // upstream kubernetes commit or range
+	PR      string // upstream kubernetes pull request number
+
+	ForkOwner      string // like origin
+	MinForkVersion string // like 4.1, inclusive
+	MaxForkVersion string // like 4.3, inclusive
+
+	DryRun bool
+}
+
+func NewBackportOptions(streams genericclioptions.IOStreams) *BackportOptions {
+	return &BackportOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdBackport cherry-picks an upstream change onto topic branches off many fork branches.
+func NewCmdBackport(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewBackportOptions(streams)
+	cmd := &cobra.Command{
+		Use: "backport --kube-home=/path/to/k8s.io (--pr=12345 | --commits=<sha>[..<sha>]) --fork-owner=origin --min-fork-version=4.1 --max-fork-version=4.3",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+The commits of the upstream pull request, or the given commits, are cherry-picked onto backport-<pr>-<branch> off of
+every fork branch of --fork-owner between --min-fork-version and --max-fork-version.  This is done in kubernetes and
+in every staging repo the change touches, using the commits the upstream publishing bot published there.  Picked
+commits get an "UPSTREAM: <pr>: " subject.  Commits already in a fork branch, or cherry-picked onto it with the same
+patch or with -x, are skipped.
+
+Topic branches that picked cleanly are pushed to the openshift remote.  Conflicts are summarized at the end.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Commits, "commits", o.Commits, "upstream kubernetes commit, or range like a..b")
+	cmd.Flags().StringVar(&o.PR, "pr", o.PR, "upstream kubernetes pull request number, found from --commits if not set")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.MinForkVersion, "min-fork-version", o.MinForkVersion, "oldest fork version to backport to, like 4.1")
+	cmd.Flags().StringVar(&o.MaxForkVersion, "max-fork-version", o.MaxForkVersion, "newest fork version to backport to, like 4.3")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "build the topic branches locally, but don't push")
+
+	return cmd
+}
+
+type pickResult struct {
+	repo   string
+	branch string
+	topic  string
+	// result is clean, already present, or conflict
+	result string
+	detail string
+}
+
+func (o *BackportOptions) Run() error {
+	if len(o.Commits) == 0 && len(o.PR) == 0 {
+		return fmt.Errorf("must have commits or pr")
+	}
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	for _, currInfo := range repoInfos {
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+	}
+	// GetAllKubeRepos puts kubernetes first
+	kubeInfo := repoInfos[0]
+
+	pr := o.PR
+	var kubeCommits []string
+	switch {
+	case len(o.Commits) > 0:
+		kubeCommits, err = kubefork.ResolveCommits(kubeInfo.Path, o.Commits)
+		if err == nil && len(pr) == 0 && len(kubeCommits) > 0 {
+			pr, err = kubefork.UpstreamPRForCommit(kubeInfo.Path, kubeCommits[len(kubeCommits)-1])
+		}
+	default:
+		_, kubeCommits, err = kubefork.UpstreamPRCommits(kubeInfo.Path, o.PR)
+	}
+	if err != nil {
+		return err
+	}
+	if len(kubeCommits) == 0 {
+		return fmt.Errorf("no commits to backport")
+	}
+	fmt.Fprintf(o.Streams.Out, "Backporting %d commits from upstream pull request #%s\n", len(kubeCommits), pr)
+
+	results := []pickResult{}
+	for _, currInfo := range repoInfos {
+		commits, err := o.commitsForRepo(kubeInfo, currInfo, kubeCommits)
+		if err != nil {
+			return err
+		}
+		if len(commits) == 0 {
+			continue
+		}
+
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		forkBranches, err := kubefork.FindOpenShiftForkBranches(repo)
+		if err != nil {
+			return err
+		}
+		for _, forkBranch := range forkBranches {
+			if !o.isTarget(forkBranch) {
+				continue
+			}
+			result, err := backportToBranch(o.Streams.Indent(), currInfo, forkBranch, pr, commits)
+			if err != nil {
+				return err
+			}
+			results = append(results, result)
+			if result.result != "clean" || o.DryRun {
+				continue
+			}
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, pushing %q to %q\n", currInfo.UpstreamName, result.topic, currInfo.Openshift.Name)
+			if err := kubefork.RunCmd(o.Streams.Indent(), currInfo.Path, "git", "push", "--force-with-lease", currInfo.Openshift.Name, result.topic); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(o.Streams.Out, "\nBackport of upstream pull request #%s:\n", pr)
+	w := tabwriter.NewWriter(o.Streams.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tBRANCH\tTOPIC\tRESULT\tDETAIL")
+	conflicts := 0
+	for _, result := range results {
+		if result.result == "conflict" {
+			conflicts++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.repo, result.branch, result.topic, result.result, result.detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return fmt.Errorf("%d of %d backports conflicted", conflicts, len(results))
+	}
+	return nil
+}
+
+func (o *BackportOptions) isTarget(forkBranch kubefork.ForkBranchInfo) bool {
+	if forkBranch.ForkOwner != o.ForkOwner {
+		return false
+	}
+	if len(o.MinForkVersion) > 0 && kubefork.CompareVersions(forkBranch.ForkVersion, o.MinForkVersion) < 0 {
+		return false
+	}
+	if len(o.MaxForkVersion) > 0 && kubefork.CompareVersions(forkBranch.ForkVersion, o.MaxForkVersion) > 0 {
+		return false
+	}
+	return true
+}
+
+// commitsForRepo maps the kubernetes commits to the commits to pick in a repo.  Staging repos get the commits the
+// upstream publishing bot published from the kubernetes commits that touch them.
+func (o *BackportOptions) commitsForRepo(kubeInfo, currInfo kubefork.RepoInfo, kubeCommits []string) ([]string, error) {
+	if currInfo.UpstreamName == kubeInfo.UpstreamName {
+		return kubeCommits, nil
+	}
+
+	touching, err := kubefork.CommitsTouching(kubeInfo.Path, kubeCommits, kubefork.StagingPath(currInfo.UpstreamName))
+	if err != nil {
+		return nil, err
+	}
+	if len(touching) == 0 {
+		return nil, nil
+	}
+	index, err := kubecommitindex.ForRepo(o.Streams.Indent(), o.KubeHome, currInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, kubeCommit := range touching {
+		stagingCommits := index.StagingCommits(kubeCommit)
+		if len(stagingCommits) == 0 {
+			return nil, fmt.Errorf("kubernetes commit %v touches %v, but was not published there", kubeCommit, currInfo.UpstreamName)
+		}
+		ret = append(ret, stagingCommits[0])
+	}
+	return ret, nil
+}
+
+// backportToBranch cherry-picks the commits onto a topic branch off the fork branch.
+func backportToBranch(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, pr string, commits []string) (pickResult, error) {
+	branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+	result := pickResult{
+		repo:   currInfo.UpstreamName,
+		branch: forkBranch.BranchName(),
+		topic:  fmt.Sprintf("backport-%s-%s", pr, forkBranch.BranchName()),
+	}
+
+	toPick := []string{}
+	for _, commit := range commits {
+		if !kubefork.IsAncestor(currInfo.Path, commit, branchRef) {
+			toPick = append(toPick, commit)
+		}
+	}
+	toPick, err := notYetPicked(currInfo, forkBranch, toPick)
+	if err != nil {
+		return result, err
+	}
+	if len(toPick) == 0 {
+		result.topic = ""
+		result.result = "already present"
+		return result, nil
+	}
+
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, picking %d commits onto %q\n", currInfo.UpstreamName, len(toPick), result.topic)
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "checkout", "-B", result.topic, branchRef); err != nil {
+		return result, err
+	}
+	// the built-in reset and cleanoptions don't seem to work.  other weird behavior is mentioned in issues
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "reset", "--hard", branchRef); err != nil {
+		return result, err
+	}
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "clean", "-fd"); err != nil {
+		return result, err
+	}
+
+	for _, commit := range toPick {
+		if err := kubefork.RunCmd(streams, currInfo.Path, "git", "cherry-pick", "-x", commit); err != nil {
+			conflicts, diffErr := kubefork.CollectCmdStdout(currInfo.Path, "git", "diff", "--name-only", "--diff-filter=U")
+			if diffErr != nil {
+				return result, diffErr
+			}
+			if err := kubefork.RunCmd(streams, currInfo.Path, "git", "cherry-pick", "--abort"); err != nil {
+				return result, err
+			}
+			result.result = "conflict"
+			result.detail = fmt.Sprintf("%s: %s", commit[:12], strings.Join(strings.Fields(conflicts), ","))
+			return result, nil
+		}
+
+		message, err := kubefork.CollectCmdStdout(currInfo.Path, "git", "log", "-1", "--format=%B")
+		if err != nil {
+			return result, err
+		}
+		lines := strings.SplitN(message, "\n", 2)
+		lines[0] = kubefork.UpstreamSubject(pr, lines[0])
+		if err := kubefork.RunCmd(streams, currInfo.Path, "git", "commit", "--amend", "--quiet", "--cleanup=verbatim", "-m", strings.Join(lines, "\n")); err != nil {
+			return result, err
+		}
+	}
+
+	result.result = "clean"
+	result.detail = fmt.Sprintf("%d commits", len(toPick))
+	return result, nil
+}
+
+// notYetPicked filters out the commits that were cherry-picked onto the fork branch already, either with the same patch
+// or with -x so that their "cherry picked from commit" line names them.
+func notYetPicked(currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, commits []string) ([]string, error) {
+	if len(commits) == 0 {
+		return commits, nil
+	}
+	carries, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, forkBranch)
+	if err != nil {
+		return nil, err
+	}
+	carryPatchIDs, err := kubefork.PatchIDs(currInfo.Path, carries)
+	if err != nil {
+		return nil, err
+	}
+	picked := map[string]bool{}
+	for _, patchID := range carryPatchIDs {
+		picked[patchID] = true
+	}
+	messages := ""
+	if len(carries) > 0 {
+		messages, err = kubefork.CollectCmdStdout(currInfo.Path, "git", append([]string{"log", "--no-walk=unsorted", "--format=%B"}, carries...)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	patchIDs, err := kubefork.PatchIDs(currInfo.Path, commits)
+	if err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for _, commit := range commits {
+		if patchID, ok := patchIDs[commit]; ok && picked[patchID] {
+			continue
+		}
+		if strings.Contains(messages, "(cherry picked from commit "+commit+")") {
+			continue
+		}
+		ret = append(ret, commit)
+	}
+	return ret, nil
+}
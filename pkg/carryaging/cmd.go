@@ -0,0 +1,228 @@
+package carryaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type CarryAgingOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Repo      string // like kubernetes, api, apimachinery, etc
+	ForkOwner string // like origin
+	All       bool   // include carries that have been dropped
+	Output    string // table or json
+}
+
+// CarryAge is how long one carry has been carried.
+type CarryAge struct {
+	Subject string `json:"subject"`
+	Kind    string `json:"kind"`
+	// UpstreamPR is the upstream pull request from the subject, empty for carries without one.
+	UpstreamPR string `json:"upstreamPR,omitempty"`
+
+	FirstForkVersion string `json:"firstForkVersion"`
+	FirstBranch      string `json:"firstBranch"`
+	LastBranch       string `json:"lastBranch"`
+	// RebasesSurvived is how many times the carry was moved to a newer fork branch.
+	RebasesSurvived int    `json:"rebasesSurvived"`
+	Commit          string `json:"commit"`
+	// Current is true when the carry is on the newest fork branch.
+	Current bool `json:"current"`
+}
+
+func NewCarryAgingOptions(streams genericclioptions.IOStreams) *CarryAgingOptions {
+	return &CarryAgingOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:      "kubernetes",
+		ForkOwner: "origin",
+		Output:    "table",
+	}
+}
+
+// NewCmdCarryAging reports how long each carry has been carried across fork versions.
+func NewCmdCarryAging(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCarryAgingOptions(streams)
+	cmd := &cobra.Command{
+		Use: "carry-aging --kube-home=/path/to/k8s.io --repo=kubernetes --fork-owner=origin",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Every <fork-owner>-<version>-kubernetes-<kube-version> branch of --repo is walked in version order and carries are
+followed from one branch to the next by patch-id and then by subject.  Each carry on the newest branch is listed with
+the fork version it first appeared in, how many rebases it has survived, and its upstream pull request if it has one.
+The oldest carries are listed first.  --all also lists carries that were dropped along the way.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().BoolVar(&o.All, "all", o.All, "include carries that were dropped before the newest fork branch")
+	cmd.Flags().StringVar(&o.Output, "output", o.Output, "output format, table or json")
+
+	return cmd
+}
+
+func (o *CarryAgingOptions) Run() error {
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if o.Output != "table" && o.Output != "json" {
+		return fmt.Errorf("output must be table or json, not %q", o.Output)
+	}
+
+	// progress goes to stderr so that the report itself can be piped
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	var currInfo *kubefork.RepoInfo
+	for i := range repoInfos {
+		if repoInfos[i].UpstreamName == o.Repo {
+			currInfo = &repoInfos[i]
+		}
+	}
+	if currInfo == nil {
+		return fmt.Errorf("unknown repo %q", o.Repo)
+	}
+	if err := kubefork.CloneRepo(progressStreams.Indent(), *currInfo); err != nil {
+		return err
+	}
+	if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), *currInfo); err != nil {
+		return err
+	}
+
+	repo, err := git.PlainOpen(currInfo.Path)
+	if err != nil {
+		return err
+	}
+	allForkBranches, err := kubefork.FindOpenShiftForkBranches(repo)
+	if err != nil {
+		return err
+	}
+	forkBranches := []kubefork.ForkBranchInfo{}
+	for _, forkBranch := range allForkBranches {
+		if forkBranch.ForkOwner == o.ForkOwner {
+			forkBranches = append(forkBranches, forkBranch)
+		}
+	}
+	if len(forkBranches) == 0 {
+		return fmt.Errorf("no %v fork branches in kubernetes/%v", o.ForkOwner, o.Repo)
+	}
+
+	ages, err := carryAges(progressStreams, *currInfo, forkBranches)
+	if err != nil {
+		return err
+	}
+	ret := []CarryAge{}
+	for _, age := range ages {
+		if age.Current || o.All {
+			ret = append(ret, age)
+		}
+	}
+
+	switch o.Output {
+	case "json":
+		encoder := json.NewEncoder(o.Streams.Out)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(ret)
+	}
+
+	w := tabwriter.NewWriter(o.Streams.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FIRST FORK VERSION\tREBASES\tKIND\tUPSTREAM PR\tCOMMIT\tLAST BRANCH\tSUBJECT")
+	for _, age := range ret {
+		upstreamPR := age.UpstreamPR
+		if len(upstreamPR) == 0 {
+			upstreamPR = "<none>"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", age.FirstForkVersion, age.RebasesSurvived, age.Kind, upstreamPR, age.Commit[:12], age.LastBranch, age.Subject)
+	}
+	return w.Flush()
+}
+
+// carryAges follows carries across fork branches given oldest first, and returns them oldest carry first.
+func carryAges(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo, forkBranches []kubefork.ForkBranchInfo) ([]CarryAge, error) {
+	ages := []*CarryAge{}
+	// agesBySHA holds the carries of the previous branch
+	agesBySHA := map[string]*CarryAge{}
+	prevCarries := []kubefork.Carry{}
+
+	for _, forkBranch := range forkBranches {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, reading carries of %q\n", currInfo.UpstreamName, forkBranch.BranchName())
+		commits, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, forkBranch)
+		if err != nil {
+			return nil, err
+		}
+		carries, err := kubefork.LoadCarries(currInfo.Path, commits)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, age := range ages {
+			age.Current = false
+		}
+		comparison := kubefork.CompareCarries(prevCarries, carries)
+		nextBySHA := map[string]*CarryAge{}
+		for _, pair := range append(comparison.Unchanged, comparison.Modified...) {
+			age := agesBySHA[pair.Old.SHA]
+			age.RebasesSurvived++
+			age.LastBranch = forkBranch.BranchName()
+			age.Commit = pair.New.SHA
+			age.Subject = pair.New.Subject
+			age.Current = true
+			nextBySHA[pair.New.SHA] = age
+		}
+		for _, carry := range comparison.Added {
+			age := &CarryAge{
+				Subject:          carry.Subject,
+				FirstForkVersion: forkBranch.ForkVersion,
+				FirstBranch:      forkBranch.BranchName(),
+				LastBranch:       forkBranch.BranchName(),
+				Commit:           carry.SHA,
+				Current:          true,
+			}
+			ages = append(ages, age)
+			nextBySHA[carry.SHA] = age
+		}
+
+		agesBySHA = nextBySHA
+		prevCarries = carries
+	}
+
+	ret := []CarryAge{}
+	for _, age := range ages {
+		// a carry that gained an upstream pull request on a later rebase is reported with it
+		subject := kubefork.ParseCarrySubject(age.Subject)
+		age.Kind = subject.Kind
+		age.UpstreamPR = subject.PR
+		ret = append(ret, *age)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].RebasesSurvived > ret[j].RebasesSurvived
+	})
+	return ret, nil
+}
@@ -0,0 +1,223 @@
+package carrydeps
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// Graph is which carries build on which.  Carry B depends on carry A when B changes or deletes lines that A introduced,
+// or inserts lines right next to them.  Those are the carries that conflict when A is dropped.
+type Graph struct {
+	// Carries are in the order they were given, oldest first.
+	Carries []string
+	// DependsOn has the carries each carry depends on, oldest first.
+	DependsOn map[string][]string
+}
+
+var diffHunkRegex = regexp.MustCompile(`^@@ -([0-9]+)(?:,([0-9]+))? \+[0-9]+(?:,[0-9]+)? @@`)
+
+// Build finds the dependencies between carries by blaming the lines each one changes in its parent.  Blame stops at
+// since, usually the upstream tag the carries are on, so only lines introduced by carries are attributed.
+func Build(repoPath, since string, carries []string) (*Graph, error) {
+	ret := &Graph{
+		Carries:   carries,
+		DependsOn: map[string][]string{},
+	}
+	carryIndex := map[string]int{}
+	for i, carry := range carries {
+		carryIndex[carry] = i
+	}
+
+	for _, carry := range carries {
+		if kubefork.IsAncestor(repoPath, carry+"^", since) {
+			// every line it changes is from upstream, and blaming an empty range would blame the work tree instead
+			continue
+		}
+		diff, err := kubefork.CollectCmdStdout(repoPath, "git", "diff", "--no-color", "--no-renames", "--no-ext-diff", "-U0", carry+"^", carry)
+		if err != nil {
+			return nil, err
+		}
+
+		dependencies := map[string]bool{}
+		oldFile := ""
+		for _, line := range strings.Split(diff, "\n") {
+			// every file starts with a diff --git line, but only files with changed lines have a --- line
+			if strings.HasPrefix(line, "diff --git ") {
+				oldFile = ""
+				continue
+			}
+			if strings.HasPrefix(line, "--- ") {
+				if oldFile, err = diffOldFile(line); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			matches := diffHunkRegex.FindStringSubmatch(line)
+			if matches == nil || len(oldFile) == 0 {
+				continue
+			}
+			start, _ := strconv.Atoi(matches[1])
+			count := 1
+			if len(matches[2]) > 0 {
+				count, _ = strconv.Atoi(matches[2])
+			}
+
+			// a pure insertion changes no lines, so it depends on whoever wrote the line it was inserted after
+			adjacentOnly := count == 0
+			if adjacentOnly {
+				if start == 0 {
+					start = 1
+				}
+				count = 1
+			}
+			authors, err := blame(repoPath, since, carry+"^", oldFile, start, start+count-1)
+			if err != nil && !adjacentOnly {
+				return nil, err
+			}
+			for _, author := range authors {
+				if _, isCarry := carryIndex[author]; isCarry && author != carry {
+					dependencies[author] = true
+				}
+			}
+		}
+
+		for dependency := range dependencies {
+			ret.DependsOn[carry] = append(ret.DependsOn[carry], dependency)
+		}
+		sort.Slice(ret.DependsOn[carry], func(i, j int) bool {
+			return carryIndex[ret.DependsOn[carry][i]] < carryIndex[ret.DependsOn[carry][j]]
+		})
+	}
+	return ret, nil
+}
+
+// diffOldFile returns the path of a --- line, or empty for /dev/null.  git quotes paths with unusual characters the way
+// C and go do, and ends paths with spaces with a tab.
+func diffOldFile(line string) (string, error) {
+	path := strings.TrimSuffix(strings.TrimPrefix(line, "--- "), "\t")
+	if path == "/dev/null" {
+		return "", nil
+	}
+	if strings.HasPrefix(path, `"`) {
+		unquoted, err := strconv.Unquote(path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read path of %q: %v", line, err)
+		}
+		path = unquoted
+	}
+	if !strings.HasPrefix(path, "a/") {
+		return "", fmt.Errorf("unable to read path of %q", line)
+	}
+	return strings.TrimPrefix(path, "a/"), nil
+}
+
+// blame returns the commits that last changed each line from start to end of file at commit.
+func blame(repoPath, since, commit, file string, start, end int) ([]string, error) {
+	out, err := kubefork.CollectCmdStdout(repoPath, "git", "blame", "-l", "-s", "-L", fmt.Sprintf("%d,%d", start, end), since+".."+commit, "--", file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to blame %s:%d,%d at %s: %v", file, start, end, commit, err)
+	}
+	ret := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// boundary commits are prefixed with ^ and are never carries
+		ret = append(ret, fields[0])
+	}
+	return ret, nil
+}
+
+// Dependents returns the carries that depend on carry, oldest first.
+func (g *Graph) Dependents(carry string) []string {
+	ret := []string{}
+	for _, curr := range g.Carries {
+		for _, dependency := range g.DependsOn[curr] {
+			if dependency == carry {
+				ret = append(ret, curr)
+			}
+		}
+	}
+	return ret
+}
+
+// Groups numbers the sets of carries connected by dependencies, starting at 1 in carry order.  A carry that nothing
+// depends on and that depends on nothing is a group by itself.
+func (g *Graph) Groups() map[string]int {
+	parent := map[string]string{}
+	var find func(string) string
+	find = func(carry string) string {
+		if parent[carry] == carry {
+			return carry
+		}
+		parent[carry] = find(parent[carry])
+		return parent[carry]
+	}
+	for _, carry := range g.Carries {
+		parent[carry] = carry
+	}
+	for _, carry := range g.Carries {
+		for _, dependency := range g.DependsOn[carry] {
+			// the older carry is the root so that groups are numbered by their oldest carry
+			parent[find(carry)] = find(dependency)
+		}
+	}
+
+	ret := map[string]int{}
+	groupNumbers := map[string]int{}
+	for _, carry := range g.Carries {
+		root := find(carry)
+		if _, ok := groupNumbers[root]; !ok {
+			groupNumbers[root] = len(groupNumbers) + 1
+		}
+		ret[carry] = groupNumbers[root]
+	}
+	return ret
+}
+
+// DroppedWithKeptDependents returns the dropped carries that kept carries depend on, with those kept carries.
+func (g *Graph) DroppedWithKeptDependents(dropped map[string]bool) map[string][]string {
+	ret := map[string][]string{}
+	for _, carry := range g.Carries {
+		if dropped[carry] {
+			continue
+		}
+		for _, dependency := range g.DependsOn[carry] {
+			if dropped[dependency] {
+				ret[dependency] = append(ret[dependency], carry)
+			}
+		}
+	}
+	return ret
+}
+
+// WriteDOT writes the graph for graphviz, with edges from each carry to the carries it depends on.  Dropped carries are
+// drawn dashed.
+func (g *Graph) WriteDOT(out io.Writer, subjects map[string]string, dropped map[string]bool) error {
+	if _, err := fmt.Fprintln(out, "digraph carries {"); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "  rankdir=BT;")
+	fmt.Fprintln(out, "  node [shape=box];")
+	for _, carry := range g.Carries {
+		style := ""
+		if dropped[carry] {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(out, "  %q [label=%q%s];\n", carry, carry[:12]+"\n"+subjects[carry], style)
+	}
+	for _, carry := range g.Carries {
+		for _, dependency := range g.DependsOn[carry] {
+			fmt.Fprintf(out, "  %q -> %q;\n", carry, dependency)
+		}
+	}
+	_, err := fmt.Fprintln(out, "}")
+	return err
+}
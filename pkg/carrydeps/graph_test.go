@@ -0,0 +1,148 @@
+package carrydeps
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffOldFile(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+		err      bool
+	}{
+		{line: "--- a/pkg/kubelet/kubelet.go", expected: "pkg/kubelet/kubelet.go"},
+		{line: "--- /dev/null", expected: ""},
+		// git ends paths with spaces with a tab
+		{line: "--- a/docs/file with spaces.md\t", expected: "docs/file with spaces.md"},
+		{line: `--- "a/\303\251.go"`, expected: "é.go"},
+		{line: `--- "a/quote\"and\\backslash.go"`, expected: `quote"and\backslash.go`},
+		{line: `--- "a/tab\there.go"`, expected: "tab\there.go"},
+		{line: "--- b/wrong-prefix.go", err: true},
+		{line: `--- "a/unterminated.go`, err: true},
+	}
+	for _, test := range tests {
+		actual, err := diffOldFile(test.line)
+		if (err != nil) != test.err {
+			t.Errorf("%q: expected error %v, got %v", test.line, test.err, err)
+			continue
+		}
+		if actual != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, actual)
+		}
+	}
+}
+
+func TestDiffHunkRegex(t *testing.T) {
+	tests := []struct {
+		line          string
+		expectedStart string
+		expectedCount string
+		match         bool
+	}{
+		{line: "@@ -12,3 +12,4 @@ func main() {", expectedStart: "12", expectedCount: "3", match: true},
+		{line: "@@ -7 +7 @@", expectedStart: "7", expectedCount: "", match: true},
+		// pure insertions change no lines and start at the line they follow
+		{line: "@@ -20,0 +21,2 @@", expectedStart: "20", expectedCount: "0", match: true},
+		{line: "@@ -0,0 +1 @@", expectedStart: "0", expectedCount: "0", match: true},
+		// pure deletions
+		{line: "@@ -5,2 +4,0 @@", expectedStart: "5", expectedCount: "2", match: true},
+		{line: "+@@ -1 +1 @@", match: false},
+		{line: "--- a/file.go", match: false},
+	}
+	for _, test := range tests {
+		matches := diffHunkRegex.FindStringSubmatch(test.line)
+		if (matches != nil) != test.match {
+			t.Errorf("%q: expected match %v, got %v", test.line, test.match, matches)
+			continue
+		}
+		if matches == nil {
+			continue
+		}
+		if matches[1] != test.expectedStart || matches[2] != test.expectedCount {
+			t.Errorf("%q: expected start %q and count %q, got %q and %q", test.line, test.expectedStart, test.expectedCount, matches[1], matches[2])
+		}
+	}
+}
+
+// testGraph is a <- b <- d, c by itself, e <- f, and a <- f.
+func testGraph() *Graph {
+	return &Graph{
+		Carries: []string{"a", "b", "c", "d", "e", "f"},
+		DependsOn: map[string][]string{
+			"b": {"a"},
+			"d": {"b"},
+			"f": {"a", "e"},
+		},
+	}
+}
+
+func TestGroups(t *testing.T) {
+	expected := map[string]int{"a": 1, "b": 1, "c": 2, "d": 1, "e": 1, "f": 1}
+	if actual := testGraph().Groups(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	separate := &Graph{
+		Carries:   []string{"a", "b", "c", "d"},
+		DependsOn: map[string][]string{"d": {"b"}},
+	}
+	expected = map[string]int{"a": 1, "b": 2, "c": 3, "d": 2}
+	if actual := separate.Groups(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestDependents(t *testing.T) {
+	graph := testGraph()
+	tests := map[string][]string{
+		"a": {"b", "f"},
+		"b": {"d"},
+		"c": {},
+		"f": {},
+	}
+	for carry, expected := range tests {
+		if actual := graph.Dependents(carry); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%v: expected %v, got %v", carry, expected, actual)
+		}
+	}
+}
+
+func TestDroppedWithKeptDependents(t *testing.T) {
+	tests := []struct {
+		name     string
+		dropped  map[string]bool
+		expected map[string][]string
+	}{
+		{
+			name:     "nothing dropped",
+			dropped:  map[string]bool{},
+			expected: map[string][]string{},
+		},
+		{
+			name:     "dropped with its dependents",
+			dropped:  map[string]bool{"b": true, "d": true},
+			expected: map[string][]string{},
+		},
+		{
+			name:     "dropped without its dependents",
+			dropped:  map[string]bool{"a": true},
+			expected: map[string][]string{"a": {"b", "f"}},
+		},
+		{
+			name:     "some dependents dropped too",
+			dropped:  map[string]bool{"a": true, "b": true, "e": true},
+			expected: map[string][]string{"a": {"f"}, "b": {"d"}, "e": {"f"}},
+		},
+		{
+			name:     "nothing depends on it",
+			dropped:  map[string]bool{"c": true},
+			expected: map[string][]string{},
+		},
+	}
+	for _, test := range tests {
+		if actual := testGraph().DroppedWithKeptDependents(test.dropped); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, actual)
+		}
+	}
+}
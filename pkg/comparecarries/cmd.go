@@ -0,0 +1,149 @@
+package comparecarries
+
+import (
+	"fmt"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type CompareCarriesOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Repo      string // like kubernetes, api, apimachinery, etc
+	OldBranch string // like origin-4.2-kubernetes-1.14.0
+	NewBranch string // like origin-4.3-kubernetes-1.16.0
+	Output    string // text or markdown
+}
+
+func NewCompareCarriesOptions(streams genericclioptions.IOStreams) *CompareCarriesOptions {
+	return &CompareCarriesOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:     "kubernetes",
+		Output:   "text",
+	}
+}
+
+// NewCmdCompareCarries reports how the carries of one fork branch became the carries of another.
+func NewCmdCompareCarries(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCompareCarriesOptions(streams)
+	cmd := &cobra.Command{
+		Use: "compare-carries --kube-home=/path/to/k8s.io --repo=kubernetes --old-branch=origin-4.2-kubernetes-1.14.0 --new-branch=origin-4.3-kubernetes-1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+The carries of the two fork branches are paired by patch-id, then by subject.  Pairs with the same patch-id are
+unchanged, pairs with only the same subject are modified and shown with a diff of their diffs like git range-diff.
+Everything else was dropped from the old branch or added on the new one.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.OldBranch, "old-branch", o.OldBranch, "fork branch before the rebase, like origin-4.2-kubernetes-1.14.0")
+	cmd.Flags().StringVar(&o.NewBranch, "new-branch", o.NewBranch, "fork branch after the rebase, like origin-4.3-kubernetes-1.16.0")
+	cmd.Flags().StringVar(&o.Output, "output", o.Output, "output format, text or markdown")
+
+	return cmd
+}
+
+func (o *CompareCarriesOptions) Run() error {
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if o.Output != "text" && o.Output != "markdown" {
+		return fmt.Errorf("output must be text or markdown, not %q", o.Output)
+	}
+	oldBranch, err := kubefork.ParseForkBranch(o.OldBranch)
+	if err != nil {
+		return fmt.Errorf("old-branch: %v", err)
+	}
+	newBranch, err := kubefork.ParseForkBranch(o.NewBranch)
+	if err != nil {
+		return fmt.Errorf("new-branch: %v", err)
+	}
+
+	// progress goes to stderr so that the report itself can be piped
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		for _, branch := range []kubefork.ForkBranchInfo{oldBranch, newBranch} {
+			if _, err := kubefork.FindOpenShiftBranch(branch.BranchName(), repo); err != nil {
+				return err
+			}
+		}
+
+		oldCarries, err := loadCarries(currInfo, oldBranch)
+		if err != nil {
+			return err
+		}
+		newCarries, err := loadCarries(currInfo, newBranch)
+		if err != nil {
+			return err
+		}
+		comparison := kubefork.CompareCarries(oldCarries, newCarries)
+
+		rangeDiffs := map[string][]string{}
+		for _, modified := range comparison.Modified {
+			rangeDiffs[modified.New.SHA], err = DiffOfDiffs(currInfo.Path, modified.Old.SHA, modified.New.SHA)
+			if err != nil {
+				return err
+			}
+		}
+
+		report := carryReport{
+			repo:       currInfo.UpstreamName,
+			oldBranch:  oldBranch.BranchName(),
+			newBranch:  newBranch.BranchName(),
+			comparison: comparison,
+			rangeDiffs: rangeDiffs,
+		}
+		if o.Output == "markdown" {
+			report.writeMarkdown(o.Streams.Out)
+		} else {
+			report.writeText(o.Streams.Out)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("missing repo %q", o.Repo)
+}
+
+func loadCarries(currInfo kubefork.RepoInfo, branch kubefork.ForkBranchInfo) ([]kubefork.Carry, error) {
+	commits, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, branch)
+	if err != nil {
+		return nil, err
+	}
+	return kubefork.LoadCarries(currInfo.Path, commits)
+}
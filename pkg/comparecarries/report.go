@@ -0,0 +1,162 @@
+package comparecarries
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+)
+
+type carryReport struct {
+	repo       string
+	oldBranch  string
+	newBranch  string
+	comparison kubefork.CarryComparison
+	// rangeDiffs are keyed by the new SHA of modified carries
+	rangeDiffs map[string][]string
+}
+
+func (r carryReport) writeText(out io.Writer) {
+	fmt.Fprintf(out, "Carries in kubernetes/%s from %s to %s\n", r.repo, r.oldBranch, r.newBranch)
+
+	fmt.Fprintf(out, "\nDropped (%d):\n", len(r.comparison.Dropped))
+	for _, carry := range r.comparison.Dropped {
+		fmt.Fprintf(out, "  %s %s\n", short(carry.SHA), carry.Subject)
+	}
+	fmt.Fprintf(out, "\nAdded (%d):\n", len(r.comparison.Added))
+	for _, carry := range r.comparison.Added {
+		fmt.Fprintf(out, "  %s %s\n", short(carry.SHA), carry.Subject)
+	}
+	fmt.Fprintf(out, "\nModified (%d):\n", len(r.comparison.Modified))
+	for _, pair := range r.comparison.Modified {
+		fmt.Fprintf(out, "  %s ! %s %s\n", short(pair.Old.SHA), short(pair.New.SHA), pair.New.Subject)
+		for _, line := range r.rangeDiffs[pair.New.SHA] {
+			fmt.Fprintf(out, "      %s\n", line)
+		}
+	}
+	fmt.Fprintf(out, "\nUnchanged (%d):\n", len(r.comparison.Unchanged))
+	for _, pair := range r.comparison.Unchanged {
+		fmt.Fprintf(out, "  %s = %s %s\n", short(pair.Old.SHA), short(pair.New.SHA), pair.New.Subject)
+	}
+}
+
+func (r carryReport) writeMarkdown(out io.Writer) {
+	fmt.Fprintf(out, "# Carries in kubernetes/%s\n\n", r.repo)
+	fmt.Fprintf(out, "From `%s` to `%s`: %d dropped, %d added, %d modified, %d unchanged.\n",
+		r.oldBranch, r.newBranch, len(r.comparison.Dropped), len(r.comparison.Added), len(r.comparison.Modified), len(r.comparison.Unchanged))
+
+	fmt.Fprintf(out, "\n## Dropped\n\n")
+	for _, carry := range r.comparison.Dropped {
+		fmt.Fprintf(out, "- `%s` %s\n", short(carry.SHA), carry.Subject)
+	}
+	fmt.Fprintf(out, "\n## Added\n\n")
+	for _, carry := range r.comparison.Added {
+		fmt.Fprintf(out, "- `%s` %s\n", short(carry.SHA), carry.Subject)
+	}
+	fmt.Fprintf(out, "\n## Modified\n")
+	for _, pair := range r.comparison.Modified {
+		fmt.Fprintf(out, "\n### %s\n\n`%s` became `%s`\n\n```diff\n", pair.New.Subject, short(pair.Old.SHA), short(pair.New.SHA))
+		for _, line := range r.rangeDiffs[pair.New.SHA] {
+			fmt.Fprintf(out, "%s\n", line)
+		}
+		fmt.Fprintf(out, "```\n")
+	}
+	fmt.Fprintf(out, "\n## Unchanged\n\n")
+	for _, pair := range r.comparison.Unchanged {
+		fmt.Fprintf(out, "- `%s` = `%s` %s\n", short(pair.Old.SHA), short(pair.New.SHA), pair.New.Subject)
+	}
+}
+
+func short(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -[0-9,]+ \+[0-9,]+ @@`)
+
+// DiffOfDiffs diffs the patches of two commits the way git range-diff does.  Blob hashes and hunk line numbers are
+// dropped from the patches first since they change on every rebase.
+func DiffOfDiffs(repoPath, oldSHA, newSHA string) ([]string, error) {
+	oldPatch, err := comparablePatch(repoPath, oldSHA)
+	if err != nil {
+		return nil, err
+	}
+	newPatch, err := comparablePatch(repoPath, newSHA)
+	if err != nil {
+		return nil, err
+	}
+	return unifiedLines(oldPatch, newPatch, 3), nil
+}
+
+func comparablePatch(repoPath, sha string) (string, error) {
+	patch, err := kubefork.CollectCmdStdout(repoPath, "git", "show", "--no-color", "--format=", "-p", sha)
+	if err != nil {
+		return "", err
+	}
+	lines := []string{}
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "index ") {
+			continue
+		}
+		lines = append(lines, hunkHeaderRegex.ReplaceAllString(line, "@@"))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// unifiedLines prefixes each line with -, + or a space and elides unchanged runs beyond context lines.
+func unifiedLines(from, to string, context int) []string {
+	type line struct {
+		op   diffmatchpatch.Operation
+		text string
+	}
+	lines := []line{}
+	for _, chunk := range diff.Do(from, to) {
+		for _, text := range strings.SplitAfter(chunk.Text, "\n") {
+			if len(text) == 0 {
+				continue
+			}
+			lines = append(lines, line{op: chunk.Type, text: strings.TrimSuffix(text, "\n")})
+		}
+	}
+
+	// keep every change and the context around it
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op == diffmatchpatch.DiffEqual {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	ret := []string{}
+	elided := false
+	for i, l := range lines {
+		if !keep[i] {
+			if !elided {
+				ret = append(ret, "...")
+				elided = true
+			}
+			continue
+		}
+		elided = false
+		switch l.op {
+		case diffmatchpatch.DiffDelete:
+			ret = append(ret, "-"+l.text)
+		case diffmatchpatch.DiffInsert:
+			ret = append(ret, "+"+l.text)
+		default:
+			ret = append(ret, " "+l.text)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,258 @@
+package contains
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubecommitindex"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type ContainsOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Commit    string // upstream kubernetes commit or range
+	PR        string // upstream kubernetes pull request number
+	ForkOwner string // optional, only check this owner's fork branches
+}
+
+func NewContainsOptions(streams genericclioptions.IOStreams) *ContainsOptions {
+	return &ContainsOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+	}
+}
+
+// NewCmdContains reports which fork branches contain an upstream change.
+func NewCmdContains(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewContainsOptions(streams)
+	cmd := &cobra.Command{
+		Use: "contains --kube-home=/path/to/k8s.io (--pr=12345 | --commit=<sha>)",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Every fork branch in kubernetes and in the staging repos the change touches is checked.  A commit is present if it
+is in the history of the fork branch, or in a staging repo if the commit published from it is.  Otherwise it is
+present as a cherry-pick if a carry on the fork branch has the same patch-id.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Commit, "commit", o.Commit, "upstream kubernetes commit, or range like a..b")
+	cmd.Flags().StringVar(&o.PR, "pr", o.PR, "upstream kubernetes pull request number")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "only check fork branches of this owner, like origin, sdn, oc")
+
+	return cmd
+}
+
+const (
+	statusPresent    = "present"
+	statusCherryPick = "present as cherry-pick"
+	statusPartial    = "partial"
+	statusMissing    = "missing"
+)
+
+type branchResult struct {
+	repo   string
+	branch string
+	status string
+	detail string
+}
+
+func (o *ContainsOptions) Run() error {
+	if (len(o.Commit) == 0) == (len(o.PR) == 0) {
+		return fmt.Errorf("must have exactly one of commit or pr")
+	}
+
+	// progress goes to stderr so that the report itself can be piped
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	for _, currInfo := range repoInfos {
+		if err := kubefork.CloneRepo(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+	}
+	// GetAllKubeRepos puts kubernetes first
+	kubeInfo := repoInfos[0]
+
+	var kubeCommits []string
+	if len(o.PR) > 0 {
+		_, kubeCommits, err = kubefork.UpstreamPRCommits(kubeInfo.Path, o.PR)
+	} else {
+		kubeCommits, err = kubefork.ResolveCommits(kubeInfo.Path, o.Commit)
+	}
+	if err != nil {
+		return err
+	}
+	if len(kubeCommits) == 0 {
+		return fmt.Errorf("no commits to look for")
+	}
+
+	results := []branchResult{}
+	for _, currInfo := range repoInfos {
+		targets, err := o.targetsForRepo(progressStreams, kubeInfo, currInfo, kubeCommits)
+		if err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			continue
+		}
+		repoResults, err := o.checkRepo(progressStreams, currInfo, targets)
+		if err != nil {
+			return err
+		}
+		results = append(results, repoResults...)
+	}
+
+	w := tabwriter.NewWriter(o.Streams.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tBRANCH\tSTATUS\tDETAIL")
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.repo, result.branch, result.status, result.detail)
+	}
+	return w.Flush()
+}
+
+// target is one upstream commit as it appears in a particular repo.
+type target struct {
+	kubeCommit string
+	// commits are what would be in the history of a fork branch that has the change
+	commits []string
+	patchID string
+}
+
+// targetsForRepo maps the kubernetes commits to what to look for in a repo.  Staging repos only get the commits that
+// touch them, found through the Kubernetes-commit index and the patch-id of the staging part of the commit.
+func (o *ContainsOptions) targetsForRepo(streams genericclioptions.IOStreams, kubeInfo, currInfo kubefork.RepoInfo, kubeCommits []string) ([]target, error) {
+	ret := []target{}
+	if currInfo.UpstreamName == kubeInfo.UpstreamName {
+		patchIDs, err := kubefork.PatchIDs(kubeInfo.Path, kubeCommits)
+		if err != nil {
+			return nil, err
+		}
+		for _, kubeCommit := range kubeCommits {
+			ret = append(ret, target{kubeCommit: kubeCommit, commits: []string{kubeCommit}, patchID: patchIDs[kubeCommit]})
+		}
+		return ret, nil
+	}
+
+	stagingPath := kubefork.StagingPath(currInfo.UpstreamName)
+	touching, err := kubefork.CommitsTouching(kubeInfo.Path, kubeCommits, stagingPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(touching) == 0 {
+		return ret, nil
+	}
+	patchIDs, err := kubefork.RelativePatchIDs(kubeInfo.Path, touching, stagingPath)
+	if err != nil {
+		return nil, err
+	}
+	index, err := kubecommitindex.ForRepo(streams.Indent(), o.KubeHome, currInfo)
+	if err != nil {
+		return nil, err
+	}
+	for _, kubeCommit := range touching {
+		ret = append(ret, target{kubeCommit: kubeCommit, commits: index.StagingCommits(kubeCommit), patchID: patchIDs[kubeCommit]})
+	}
+	return ret, nil
+}
+
+func (o *ContainsOptions) checkRepo(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo, targets []target) ([]branchResult, error) {
+	repo, err := git.PlainOpen(currInfo.Path)
+	if err != nil {
+		return nil, err
+	}
+	forkBranches, err := kubefork.FindOpenShiftForkBranches(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []branchResult{}
+	for _, forkBranch := range forkBranches {
+		if len(o.ForkOwner) > 0 && forkBranch.ForkOwner != o.ForkOwner {
+			continue
+		}
+		branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+
+		carries, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, forkBranch)
+		if err != nil {
+			fmt.Fprintf(streams.ErrOut, "For kubernetes/%v, unable to list carries of %q, only checking history: %v\n", currInfo.UpstreamName, forkBranch.BranchName(), err)
+		}
+		carryPatchIDs, err := kubefork.PatchIDs(currInfo.Path, carries)
+		if err != nil {
+			return nil, err
+		}
+		carriesByPatchID := map[string]string{}
+		for carry, patchID := range carryPatchIDs {
+			carriesByPatchID[patchID] = carry
+		}
+
+		inHistory := 0
+		cherryPicked := []string{}
+		for _, currTarget := range targets {
+			found := false
+			for _, commit := range currTarget.commits {
+				if kubefork.IsAncestor(currInfo.Path, commit, branchRef) {
+					found = true
+					break
+				}
+			}
+			if found {
+				inHistory++
+				continue
+			}
+			if carry, ok := carriesByPatchID[currTarget.patchID]; ok && len(currTarget.patchID) > 0 {
+				cherryPicked = append(cherryPicked, shortSHA(currTarget.kubeCommit)+" as "+shortSHA(carry))
+			}
+		}
+
+		result := branchResult{repo: currInfo.UpstreamName, branch: forkBranch.BranchName()}
+		found := inHistory + len(cherryPicked)
+		switch {
+		case found == len(targets) && len(cherryPicked) == 0:
+			result.status = statusPresent
+		case found == len(targets):
+			result.status = statusCherryPick
+		case found > 0:
+			result.status = statusPartial
+		default:
+			result.status = statusMissing
+		}
+		if len(targets) > 1 || len(cherryPicked) > 0 {
+			result.detail = fmt.Sprintf("%d/%d commits", found, len(targets))
+			if len(cherryPicked) > 0 {
+				result.detail += ", picked " + strings.Join(cherryPicked, ", ")
+			}
+		}
+		ret = append(ret, result)
+	}
+	return ret, nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
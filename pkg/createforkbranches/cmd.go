@@ -0,0 +1,271 @@
+package createforkbranches
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+)
+
+type CreateForkBranchesOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome    string
+	ForkOwner   string // like origin
+	ForkVersion string // like 4.2
+	KubeVersion string // like 1.15.0
+	// From is an existing fork branch to start from instead of the upstream tag, like origin-4.2-kubernetes-1.15.0
+	From string
+
+	// Journal records every branch this run creates.  Defaults to a new file in KubeHome.
+	Journal string
+	// Rollback is a journal from a previous run whose branches should be deleted instead of creating any.
+	Rollback string
+}
+
+func NewCreateForkBranchesOptions(streams genericclioptions.IOStreams) *CreateForkBranchesOptions {
+	return &CreateForkBranchesOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+	}
+}
+
+// NewCmdCreateForkBranches creates <owner>-<version>-kubernetes-<kube-version> branches in every fork repo.
+func NewCmdCreateForkBranches(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewCreateForkBranchesOptions(streams)
+	cmd := &cobra.Command{
+		Use: "create-fork-branches --kube-home=/path/to/k8s.io --fork-owner=sdn --fork-version=4.3 --kube-version=1.16.0 [--from=origin-4.3-kubernetes-1.16.0]",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+The new branches start from the upstream tag for --kube-version, or from the existing fork branch named by --from in
+each repo.  --from must be for the same kube version.
+
+Every branch created is recorded in --journal.  If a run fails partway, --rollback=<journal> deletes exactly those
+branches from the openshift remotes, skipping any that have had something pushed on top of them since.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	o.AddFlags(cmd)
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.From, "from", o.From, "existing fork branch to start from instead of the upstream tag, like origin-4.2-kubernetes-1.14.0")
+
+	return cmd
+}
+
+// AddFlags adds the flags shared with create-kube-branch-for-origin.
+func (o *CreateForkBranchesOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.14.1")
+	cmd.Flags().StringVar(&o.Journal, "journal", o.Journal, "file to record created branches in, defaults to a new file in --kube-home")
+	cmd.Flags().StringVar(&o.Rollback, "rollback", o.Rollback, "journal from a previous run, delete the branches it created instead of creating any")
+}
+
+func (o *CreateForkBranchesOptions) Run() error {
+	if len(o.Rollback) > 0 {
+		journal, err := kubefork.ReadRefJournal(o.Rollback)
+		if err != nil {
+			return err
+		}
+		return journal.Rollback(o.Streams)
+	}
+
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+	if len(o.From) > 0 {
+		fromBranch, err := kubefork.ParseForkBranch(o.From)
+		if err != nil {
+			return err
+		}
+		if fromBranch.KubeVersion != o.KubeVersion {
+			return fmt.Errorf("--from=%v is for kube %v, not %v", o.From, fromBranch.KubeVersion, o.KubeVersion)
+		}
+		if fromBranch == forkBranch {
+			return fmt.Errorf("--from=%v is the branch being created", o.From)
+		}
+	}
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	repoInfos, err = kubefork.ReposForKubeVersion(repoInfos, o.KubeVersion)
+	if err != nil {
+		return err
+	}
+
+	openshiftRemotes := map[string]*config.RemoteConfig{}
+	for i := range repoInfos {
+		currInfo := repoInfos[i]
+		fmt.Fprintf(o.Streams.Out, "Check kubernetes/%v\n", currInfo.UpstreamName)
+
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		_, openshiftRemote, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo)
+		if err != nil {
+			return err
+		}
+		openshiftRemotes[currInfo.UpstreamName] = openshiftRemote.Config()
+	}
+
+	// nothing gets pushed unless every repo can get its branch
+	if err := o.preflight(repoInfos); err != nil {
+		return err
+	}
+
+	journalPath := o.Journal
+	if len(journalPath) == 0 {
+		journalPath = filepath.Join(o.KubeHome, fmt.Sprintf("create-%s-%s.journal.json", forkBranch.BranchName(), time.Now().UTC().Format("20060102-150405")))
+	}
+	journal, err := kubefork.NewRefJournal(journalPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Streams.Out, "Recording created branches in %q\n", journal.Path())
+
+	for i := range repoInfos {
+		currInfo := repoInfos[i]
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		if err := pushForkBranches(o.Streams.Indent(), repo, currInfo, forkBranch, o.startingRef(currInfo), openshiftRemotes[currInfo.UpstreamName], journal); err != nil {
+			return fmt.Errorf("%v; to delete the branches created so far, run with --rollback=%s", err, journal.Path())
+		}
+	}
+
+	return nil
+}
+
+// preflight checks that the upstream tag exists in every repo and that each staging repo's tag was published from the
+// kubernetes tag, with no later changes to the staging directory before the kubernetes tag.  When starting --from another fork branch, that branch must exist in every repo.
+func (o *CreateForkBranchesOptions) preflight(repoInfos []kubefork.RepoInfo) error {
+	fmt.Fprintf(o.Streams.Out, "Checking upstream tags for kube %v\n", o.KubeVersion)
+	streams := o.Streams.Indent()
+
+	// ReposForKubeVersion puts kubernetes first
+	kubeInfo := repoInfos[0]
+	kubeTag := kubefork.UpstreamTag(kubeInfo.UpstreamName, o.KubeVersion)
+	problems := []string{}
+	for _, currInfo := range repoInfos {
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		if len(o.From) > 0 {
+			if _, err := kubefork.FindOpenShiftBranch(o.From, repo); err != nil {
+				problems = append(problems, fmt.Sprintf("kubernetes/%v is missing --from branch %q", currInfo.UpstreamName, o.From))
+			}
+		}
+
+		tagName := kubefork.UpstreamTag(currInfo.UpstreamName, o.KubeVersion)
+		tagRef, err := kubefork.FindKubeTag(tagName, repo)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("kubernetes/%v is missing tag %q", currInfo.UpstreamName, tagName))
+			continue
+		}
+		tagCommit, err := kubefork.ReferenceCommit(repo, tagRef)
+		if err != nil {
+			return err
+		}
+		if currInfo.UpstreamName == "kubernetes" {
+			fmt.Fprintf(streams.Out, "For kubernetes/%v, %q is %v\n", currInfo.UpstreamName, tagName, tagCommit.Hash)
+			continue
+		}
+
+		kubeCommit := kubefork.KubernetesCommit(tagCommit.Message)
+		if len(kubeCommit) == 0 {
+			problems = append(problems, fmt.Sprintf("kubernetes/%v tag %q at %v has no %s trailer", currInfo.UpstreamName, tagName, tagCommit.Hash, kubefork.KubernetesCommitTrailer))
+			continue
+		}
+		if _, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", "merge-base", "--is-ancestor", kubeCommit, kubeTag); err != nil {
+			problems = append(problems, fmt.Sprintf("kubernetes/%v tag %q was published from %v which is not in kubernetes %q", currInfo.UpstreamName, tagName, kubeCommit, kubeTag))
+			continue
+		}
+		// an older staging tag is published from an ancestor too, so kubernetes must not have changed the staging repo since
+		unpublished, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", "rev-list", "--max-count=1", kubeCommit+".."+kubeTag, "--", kubefork.StagingPath(currInfo.UpstreamName))
+		if err != nil {
+			return err
+		}
+		if unpublished = strings.TrimSpace(unpublished); len(unpublished) > 0 {
+			problems = append(problems, fmt.Sprintf("kubernetes/%v tag %q was published from %v, but kubernetes %q changed %s after it in %v", currInfo.UpstreamName, tagName, kubeCommit, kubeTag, kubefork.StagingPath(currInfo.UpstreamName), unpublished))
+			continue
+		}
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, %q is %v published from %v\n", currInfo.UpstreamName, tagName, tagCommit.Hash, kubeCommit)
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(streams.ErrOut, "%s\n", problem)
+		}
+		return fmt.Errorf("preflight failed for kube %v, no branches were created", o.KubeVersion)
+	}
+	return nil
+}
+
+// startingRef is what the new fork branch is created from in the repo.
+func (o *CreateForkBranchesOptions) startingRef(currInfo kubefork.RepoInfo) string {
+	if len(o.From) > 0 {
+		return "openshift/" + o.From
+	}
+	return kubefork.UpstreamTag(currInfo.UpstreamName, o.KubeVersion)
+}
+
+func pushForkBranches(streams genericclioptions.IOStreams, repo *git.Repository, currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, startingRef string, openshiftRemoteConfig *config.RemoteConfig, journal *kubefork.RefJournal) error {
+	repoPath := currInfo.Path
+	upstreamName := currInfo.UpstreamName
+	forkBranchName := forkBranch.BranchName()
+
+	if _, err := kubefork.FindOpenShiftBranch(forkBranchName, repo); err == nil {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, branch %q already exists, doing nothing\n", upstreamName, forkBranchName)
+		return nil
+	}
+
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, pushing %q from %q to %q\n", upstreamName, forkBranchName, startingRef, openshiftRemoteConfig.Name)
+	if err := kubefork.RunCmd(streams, repoPath, "git", "checkout", "-B", forkBranchName, startingRef); err != nil {
+		return err
+	}
+
+	// the built-in reset and cleanoptions don't seem to work.  other weird behavior is mentioned in issues
+	if err := kubefork.RunCmd(streams, repoPath, "git", "reset", "--hard", startingRef); err != nil {
+		return err
+	}
+	if err := kubefork.RunCmd(streams, repoPath, "git", "clean", "-fd"); err != nil {
+		return err
+	}
+
+	if err := journal.RecordBranch(currInfo, openshiftRemoteConfig.Name, forkBranchName); err != nil {
+		return err
+	}
+	// push to openshift
+	if err := kubefork.RunCmd(streams, repoPath, "git", "push", "openshift", forkBranchName); err != nil {
+		return err
+	}
+
+	return nil
+}
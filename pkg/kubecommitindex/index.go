@@ -0,0 +1,155 @@
+package kubecommitindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// Index maps kubernetes commits to the staging repo commits that were published from them using the Kubernetes-commit
+// trailer.  It is persisted under the kube home so that history is only scanned once.
+type Index struct {
+	path string
+
+	Repo string `json:"repo"`
+	// Tips are the commits that were scanned up to.  Later scans stop at them.
+	Tips []string `json:"tips"`
+	// KubeToStaging has more than one staging commit when a kubernetes commit was published to more than one branch.
+	KubeToStaging map[string][]string `json:"kubeToStaging"`
+	StagingToKube map[string]string   `json:"stagingToKube"`
+}
+
+// IndexPath is where the index for a staging repo is kept.
+func IndexPath(kubeHome, upstreamName string) string {
+	return filepath.Join(kubeHome, ".kube-commit-index", upstreamName+".json")
+}
+
+// Load reads the index for a staging repo, returning an empty index if there isn't one yet.
+func Load(kubeHome, upstreamName string) (*Index, error) {
+	ret := &Index{
+		path:          IndexPath(kubeHome, upstreamName),
+		Repo:          upstreamName,
+		Tips:          []string{},
+		KubeToStaging: map[string][]string{},
+		StagingToKube: map[string]string{},
+	}
+	content, err := ioutil.ReadFile(ret.path)
+	if os.IsNotExist(err) {
+		return ret, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, ret); err != nil {
+		return nil, fmt.Errorf("unable to read %q: %v", ret.path, err)
+	}
+	return ret, nil
+}
+
+// ForRepo loads the index for a staging repo and brings it up to date with every branch and tag in the clone.
+func ForRepo(streams genericclioptions.IOStreams, kubeHome string, currInfo kubefork.RepoInfo) (*Index, error) {
+	index, err := Load(kubeHome, currInfo.UpstreamName)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Update(streams, currInfo.Path); err != nil {
+		return nil, err
+	}
+	if err := index.Save(); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// Update scans every commit reachable from the remote branches and tags that has not been scanned before.
+func (i *Index) Update(streams genericclioptions.IOStreams, repoPath string) error {
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, indexing %s trailers\n", i.Repo, kubefork.KubernetesCommitTrailer)
+
+	args := []string{"log", "-z", "--format=%H%n%B", "--remotes", "--tags"}
+	// tips that were rewritten and garbage collected can't be excluded, they just cost a rescan
+	for _, tip := range i.Tips {
+		if _, err := kubefork.CollectCmdStdout(repoPath, "git", "cat-file", "-e", tip+"^{commit}"); err == nil {
+			args = append(args, "^"+tip)
+		}
+	}
+	log, err := kubefork.CollectCmdStdout(repoPath, "git", args...)
+	if err != nil {
+		return err
+	}
+
+	found := 0
+	for _, record := range strings.Split(log, "\x00") {
+		record = strings.TrimLeft(record, "\n")
+		if len(record) == 0 {
+			continue
+		}
+		lines := strings.SplitN(record, "\n", 2)
+		if len(lines) < 2 {
+			continue
+		}
+		kubeCommit := kubefork.KubernetesCommit(lines[1])
+		if len(kubeCommit) == 0 {
+			continue
+		}
+		i.add(kubeCommit, lines[0])
+		found++
+	}
+
+	tips, err := kubefork.CollectCmdStdout(repoPath, "git", "rev-parse", "--remotes", "--tags")
+	if err != nil {
+		return err
+	}
+	i.Tips = uniqueSorted(strings.Fields(tips))
+	fmt.Fprintf(streams.Indent().Out, "Indexed %d new commits, %d total\n", found, len(i.StagingToKube))
+	return nil
+}
+
+func (i *Index) add(kubeCommit, stagingCommit string) {
+	if _, ok := i.StagingToKube[stagingCommit]; ok {
+		return
+	}
+	i.StagingToKube[stagingCommit] = kubeCommit
+	i.KubeToStaging[kubeCommit] = append(i.KubeToStaging[kubeCommit], stagingCommit)
+}
+
+func (i *Index) Save() error {
+	if err := os.MkdirAll(filepath.Dir(i.path), 0755); err != nil {
+		return err
+	}
+	content, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(i.path, content, 0644)
+}
+
+// StagingCommits returns the staging commits published from a kubernetes commit.
+func (i *Index) StagingCommits(kubeCommit string) []string {
+	return i.KubeToStaging[kubeCommit]
+}
+
+// KubeCommit returns the kubernetes commit a staging commit was published from, empty if it wasn't published.
+func (i *Index) KubeCommit(stagingCommit string) string {
+	return i.StagingToKube[stagingCommit]
+}
+
+func uniqueSorted(in []string) []string {
+	seen := map[string]bool{}
+	ret := []string{}
+	for _, s := range in {
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		ret = append(ret, s)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -2,9 +2,15 @@ package kubefork
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 )
 
 func UpstreamTag(upstreamRepo, kubeVersion string) string {
@@ -85,3 +91,152 @@ func FindKubeTag(name string, repo *git.Repository) (*plumbing.Reference, error)
 
 	return ret, nil
 }
+
+var forkBranchRegex = regexp.MustCompile(`^(.+?)-([0-9]+(?:\.[0-9]+)*)-kubernetes-([0-9]+\.[0-9]+\.[0-9]+.*)$`)
+
+// ParseForkBranch is the inverse of BranchName.  It takes a name like origin-4.2-kubernetes-1.14.0.
+func ParseForkBranch(name string) (ForkBranchInfo, error) {
+	matches := forkBranchRegex.FindStringSubmatch(name)
+	if matches == nil {
+		return ForkBranchInfo{}, fmt.Errorf("%q is not a fork branch, expected <owner>-<version>-kubernetes-<kube-version>", name)
+	}
+	return NewForkBranch(matches[1], matches[2], matches[3]), nil
+}
+
+// FindOpenShiftForkBranches returns every fork branch present on the openshift remote, sorted by owner and version.
+func FindOpenShiftForkBranches(repo *git.Repository) ([]ForkBranchInfo, error) {
+	allReferences, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []ForkBranchInfo{}
+	err = allReferences.ForEach(func(ref *plumbing.Reference) error {
+		if !strings.HasPrefix(ref.Strings()[0], "refs/remotes/openshift/") {
+			return nil
+		}
+		forkBranch, err := ParseForkBranch(ref.Strings()[0][len("refs/remotes/openshift/"):])
+		if err != nil {
+			return nil
+		}
+		ret = append(ret, forkBranch)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	SortForkBranches(ret)
+	return ret, nil
+}
+
+// SortForkBranches orders by owner, then fork version, then kube version.
+func SortForkBranches(branches []ForkBranchInfo) {
+	sort.SliceStable(branches, func(i, j int) bool {
+		if branches[i].ForkOwner != branches[j].ForkOwner {
+			return branches[i].ForkOwner < branches[j].ForkOwner
+		}
+		if c := CompareVersions(branches[i].ForkVersion, branches[j].ForkVersion); c != 0 {
+			return c < 0
+		}
+		return CompareVersions(branches[i].KubeVersion, branches[j].KubeVersion) < 0
+	})
+}
+
+// CompareVersions compares dotted versions like 4.2 and 4.10 numerically.  Non-numeric segments compare as strings.
+func CompareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil && aNum < bNum:
+			return -1
+		case aErr == nil && bErr == nil && aNum > bNum:
+			return 1
+		case aErr == nil && bErr == nil:
+			continue
+		case aParts[i] < bParts[i]:
+			return -1
+		case aParts[i] > bParts[i]:
+			return 1
+		}
+	}
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	}
+	return 0
+}
+
+// CompareKubeVersions compares kube versions like 1.16.2 and 1.16.0-rc.1.  Pre-releases come before their release.
+func CompareKubeVersions(a, b string) int {
+	aParts := strings.SplitN(a, "-", 2)
+	bParts := strings.SplitN(b, "-", 2)
+	if c := CompareVersions(aParts[0], bParts[0]); c != 0 {
+		return c
+	}
+	switch {
+	case len(aParts) == 1 && len(bParts) == 1:
+		return 0
+	case len(aParts) == 1:
+		return 1
+	case len(bParts) == 1:
+		return -1
+	}
+	return CompareVersions(aParts[1], bParts[1])
+}
+
+// IsPreRelease is true for kube versions like 1.17.0-alpha.0 and 1.16.0-rc.1.
+func IsPreRelease(kubeVersion string) bool {
+	return strings.Contains(kubeVersion, "-")
+}
+
+// ReferenceCommit resolves a branch or tag reference, annotated or not, to the commit it points at.
+func ReferenceCommit(repo *git.Repository, ref *plumbing.Reference) (*object.Commit, error) {
+	tag, err := repo.TagObject(ref.Hash())
+	switch {
+	case err == nil:
+		return tag.Commit()
+	case err != plumbing.ErrObjectNotFound:
+		return nil, err
+	}
+	return repo.CommitObject(ref.Hash())
+}
+
+// StagingRepoNames lists the staging repos that kubernetes had at the upstream tag for kubeVersion.  This is the set of
+// repos that should have a fork branch for that kube version.
+func StagingRepoNames(kubeRepo *git.Repository, kubeVersion string) ([]string, error) {
+	tagName := UpstreamTag("kubernetes", kubeVersion)
+	tagRef, err := FindKubeTag(tagName, kubeRepo)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := ReferenceCommit(kubeRepo, tagRef)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	stagingTree, err := tree.Tree("staging/src/k8s.io")
+	if err == object.ErrDirectoryNotFound {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, entry := range stagingTree.Entries {
+		if entry.Mode == filemode.Dir {
+			ret = append(ret, entry.Name)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
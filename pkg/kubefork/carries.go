@@ -0,0 +1,224 @@
+package kubefork
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// OpenShiftBranchRef is the remote tracking ref for a fork branch, usable as a git revision.
+func OpenShiftBranchRef(branch ForkBranchInfo) string {
+	return "openshift/" + branch.BranchName()
+}
+
+// ListCarries returns the non-merge commits on the fork branch that are not part of upstream, oldest first.  Fork
+// branches that merged later upstream patch releases start from the most recent merged tag, and anything reachable from
+// upstream branches or tags is left out so that only commits unique to the fork remain.
+func ListCarries(repoPath, upstreamName string, branch ForkBranchInfo) ([]string, error) {
+	branchRef := OpenShiftBranchRef(branch)
+	startingTag, err := MergedUpstreamTag(repoPath, upstreamName, branchRef)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := CollectCmdStdout(repoPath, "git", "rev-list", startingTag+".."+branchRef, "--no-merges", "--reverse",
+		"--not", "--remotes=upstream", "--tags="+UpstreamTagPattern(upstreamName))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, commit := range strings.Split(commits, "\n") {
+		if len(commit) == 0 {
+			continue
+		}
+		ret = append(ret, commit)
+	}
+	return ret, nil
+}
+
+// UpstreamTagPattern is the glob matching the upstream release tags of a repo.
+func UpstreamTagPattern(upstreamName string) string {
+	return UpstreamTag(upstreamName, "[0-9]*")
+}
+
+// MergedUpstreamTag returns the newest upstream release in the history of ref.  For a fork branch that has not merged
+// any later upstream tags, this is the tag it was created from.  Pre-releases like v1.17.0-alpha.0, which upstream tags
+// where the previous release branch was cut, only count if there are no releases.
+func MergedUpstreamTag(repoPath, upstreamName, ref string) (string, error) {
+	tags, err := MergedUpstreamTags(repoPath, upstreamName, ref)
+	if err != nil {
+		return "", err
+	}
+	if len(tags) == 0 {
+		return "", fmt.Errorf("unable to find an upstream tag in %q", ref)
+	}
+	prefix := UpstreamTag(upstreamName, "")
+	for i := len(tags) - 1; i >= 0; i-- {
+		if !IsPreRelease(strings.TrimPrefix(tags[i], prefix)) {
+			return tags[i], nil
+		}
+	}
+	return tags[len(tags)-1], nil
+}
+
+// MergedUpstreamTags returns every upstream tag in the history of ref, oldest version first.  The nearest tag isn't
+// necessarily the newest, because upstream patch releases are merged in from the side.
+func MergedUpstreamTags(repoPath, upstreamName, ref string) ([]string, error) {
+	out, err := CollectCmdStdout(repoPath, "git", "tag", "--list", "--merged", ref, UpstreamTagPattern(upstreamName))
+	if err != nil {
+		return nil, fmt.Errorf("unable to list upstream tags in %q: %v", ref, err)
+	}
+	prefix := UpstreamTag(upstreamName, "")
+	tags := strings.Fields(out)
+	sort.SliceStable(tags, func(i, j int) bool {
+		return CompareKubeVersions(strings.TrimPrefix(tags[i], prefix), strings.TrimPrefix(tags[j], prefix)) < 0
+	})
+	return tags, nil
+}
+
+// Carry is a fork commit identified well enough to find it again after it has been cherry-picked.
+type Carry struct {
+	SHA     string
+	Subject string
+	// PatchID is git patch-id --stable, empty for commits without a diff.
+	PatchID string
+}
+
+// LoadCarries looks up the subjects and patch-ids of commits, keeping their order.
+func LoadCarries(repoPath string, commits []string) ([]Carry, error) {
+	if len(commits) == 0 {
+		return []Carry{}, nil
+	}
+
+	subjects, err := CollectCmdStdout(repoPath, "git", append([]string{"log", "--no-walk=unsorted", "--format=%H%x00%s"}, commits...)...)
+	if err != nil {
+		return nil, err
+	}
+	subjectsBySHA := map[string]string{}
+	for _, line := range strings.Split(subjects, "\n") {
+		parts := strings.SplitN(line, "\x00", 2)
+		if len(parts) == 2 {
+			subjectsBySHA[parts[0]] = parts[1]
+		}
+	}
+	patchIDs, err := PatchIDs(repoPath, commits)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Carry{}
+	for _, commit := range commits {
+		ret = append(ret, Carry{SHA: commit, Subject: subjectsBySHA[commit], PatchID: patchIDs[commit]})
+	}
+	return ret, nil
+}
+
+// PatchIDs returns git patch-id --stable for each commit, leaving out commits without a diff.
+func PatchIDs(repoPath string, commits []string) (map[string]string, error) {
+	return patchIDs(repoPath, commits)
+}
+
+// RelativePatchIDs returns the patch-ids of only the part of each commit under subdir, with paths relative to it.  For
+// a kubernetes commit and a staging path, this is the patch-id of the commit published to the staging repo.
+func RelativePatchIDs(repoPath string, commits []string, subdir string) (map[string]string, error) {
+	return patchIDs(repoPath, commits, "--relative="+subdir, "--", subdir)
+}
+
+func patchIDs(repoPath string, commits []string, showArgs ...string) (map[string]string, error) {
+	ret := map[string]string{}
+	if len(commits) == 0 {
+		return ret, nil
+	}
+
+	args := append([]string{"show", "--no-color", "--format=commit %H", "-p"}, commits...)
+	patches, err := CollectCmdStdout(repoPath, "git", append(args, showArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", "patch-id", "--stable")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(patches)
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			ret[fields[1]] = fields[0]
+		}
+	}
+	return ret, nil
+}
+
+// CarryPair is the same carry on two fork branches.
+type CarryPair struct {
+	Old Carry
+	New Carry
+}
+
+// CarryComparison is how the carries of one fork branch became the carries of another.
+type CarryComparison struct {
+	// Unchanged carries have the same patch-id.
+	Unchanged []CarryPair
+	// Modified carries have the same subject, but a different patch.
+	Modified []CarryPair
+	Dropped  []Carry
+	Added    []Carry
+}
+
+// CompareCarries pairs carries by patch-id and then by subject.  Each carry is paired at most once.
+func CompareCarries(oldCarries, newCarries []Carry) CarryComparison {
+	ret := CarryComparison{}
+	pairedOld := map[int]bool{}
+	pairedNew := map[int]bool{}
+
+	pair := func(matches func(oldCarry, newCarry Carry) bool, add func(CarryPair)) {
+		for i, oldCarry := range oldCarries {
+			if pairedOld[i] {
+				continue
+			}
+			for j, newCarry := range newCarries {
+				if pairedNew[j] || !matches(oldCarry, newCarry) {
+					continue
+				}
+				pairedOld[i] = true
+				pairedNew[j] = true
+				add(CarryPair{Old: oldCarry, New: newCarry})
+				break
+			}
+		}
+	}
+	pair(
+		func(oldCarry, newCarry Carry) bool {
+			return len(oldCarry.PatchID) > 0 && oldCarry.PatchID == newCarry.PatchID
+		},
+		func(p CarryPair) { ret.Unchanged = append(ret.Unchanged, p) },
+	)
+	pair(
+		func(oldCarry, newCarry Carry) bool {
+			return NormalizeSubject(oldCarry.Subject) == NormalizeSubject(newCarry.Subject)
+		},
+		func(p CarryPair) { ret.Modified = append(ret.Modified, p) },
+	)
+
+	for i, oldCarry := range oldCarries {
+		if !pairedOld[i] {
+			ret.Dropped = append(ret.Dropped, oldCarry)
+		}
+	}
+	for j, newCarry := range newCarries {
+		if !pairedNew[j] {
+			ret.Added = append(ret.Added, newCarry)
+		}
+	}
+	return ret
+}
+
+// NormalizeSubject makes subjects comparable across rebases that only reworded whitespace or case.
+func NormalizeSubject(subject string) string {
+	return strings.ToLower(strings.Join(strings.Fields(subject), " "))
+}
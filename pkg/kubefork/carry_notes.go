@@ -0,0 +1,241 @@
+package kubefork
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+)
+
+// CarryNotesRef holds the review decisions for carries as git notes on the carry commits.
+const CarryNotesRef = "refs/notes/carries"
+
+// remoteCarryNotesRef is where FetchUpdates fetches the carry notes of a remote to.
+func remoteCarryNotesRef(remoteName string) string {
+	return "refs/notes/remotes/" + remoteName + "/carries"
+}
+
+// CarryNote is what a pick list review decided about a carry.  It is stored as "key: value" lines.
+type CarryNote struct {
+	// Decision is pick, drop, or squash.
+	Decision   string
+	UpstreamPR string
+	Owner      string
+	Rationale  string
+}
+
+const (
+	carryNoteDecision   = "decision"
+	carryNoteUpstreamPR = "upstream-pr"
+	carryNoteOwner      = "owner"
+	carryNoteRationale  = "rationale"
+)
+
+// Merge returns the note with the non-empty fields of newer replacing its own.
+func (n CarryNote) Merge(newer CarryNote) CarryNote {
+	if len(newer.Decision) > 0 {
+		n.Decision = newer.Decision
+	}
+	if len(newer.UpstreamPR) > 0 {
+		n.UpstreamPR = newer.UpstreamPR
+	}
+	if len(newer.Owner) > 0 {
+		n.Owner = newer.Owner
+	}
+	if len(newer.Rationale) > 0 {
+		n.Rationale = newer.Rationale
+	}
+	return n
+}
+
+func (n CarryNote) String() string {
+	lines := []string{}
+	add := func(key, value string) {
+		// values are single lines so that the note stays parseable
+		value = strings.Join(strings.Fields(value), " ")
+		if len(value) > 0 {
+			lines = append(lines, key+": "+value)
+		}
+	}
+	add(carryNoteDecision, n.Decision)
+	add(carryNoteUpstreamPR, n.UpstreamPR)
+	add(carryNoteOwner, n.Owner)
+	add(carryNoteRationale, n.Rationale)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ParseCarryNote reads "key: value" lines.  Unknown keys are ignored and the last value for a key wins.
+func ParseCarryNote(content string) CarryNote {
+	ret := CarryNote{}
+	for _, line := range strings.Split(content, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		switch strings.TrimSpace(parts[0]) {
+		case carryNoteDecision:
+			ret.Decision = value
+		case carryNoteUpstreamPR:
+			ret.UpstreamPR = value
+		case carryNoteOwner:
+			ret.Owner = value
+		case carryNoteRationale:
+			ret.Rationale = value
+		}
+	}
+	return ret
+}
+
+// ReadCarryNotes returns every carry note in the repo by the commit it is attached to.
+func ReadCarryNotes(repoPath string) (map[string]CarryNote, error) {
+	ret := map[string]CarryNote{}
+	if _, err := CollectCmdStdout(repoPath, "git", "rev-parse", "--verify", "--quiet", CarryNotesRef); err != nil {
+		// no notes yet
+		return ret, nil
+	}
+
+	list, err := CollectCmdStdout(repoPath, "git", "notes", "--ref="+CarryNotesRef, "list")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(list, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		content, err := CollectCmdStdout(repoPath, "git", "cat-file", "blob", fields[0])
+		if err != nil {
+			return nil, err
+		}
+		ret[fields[1]] = ParseCarryNote(content)
+	}
+	return ret, nil
+}
+
+// WriteCarryNote merges note into the note already on commit.
+func WriteCarryNote(streams genericclioptions.IOStreams, repoPath, commit string, note CarryNote) error {
+	existing := CarryNote{}
+	if content, err := CollectCmdStdout(repoPath, "git", "notes", "--ref="+CarryNotesRef, "show", commit); err == nil {
+		existing = ParseCarryNote(content)
+	}
+	merged := existing.Merge(note)
+	if merged == existing {
+		return nil
+	}
+	return RunCmd(streams, repoPath, "git", "notes", "--ref="+CarryNotesRef, "add", "--force", "--message", merged.String(), commit)
+}
+
+// MergeCarryNotes merges the carry notes fetched from the openshift remote into the local ones.  Notes on the remote
+// win when both changed the same commit.
+func MergeCarryNotes(streams genericclioptions.IOStreams, currInfo RepoInfo) error {
+	remoteRef := remoteCarryNotesRef(currInfo.Openshift.Name)
+	if _, err := CollectCmdStdout(currInfo.Path, "git", "rev-parse", "--verify", "--quiet", remoteRef); err != nil {
+		// the remote has no notes
+		return nil
+	}
+	if _, err := CollectCmdStdout(currInfo.Path, "git", "rev-parse", "--verify", "--quiet", CarryNotesRef); err != nil {
+		return RunCmd(streams, currInfo.Path, "git", "update-ref", CarryNotesRef, remoteRef)
+	}
+	return RunCmd(streams, currInfo.Path, "git", "notes", "--ref="+CarryNotesRef, "merge", "--strategy=theirs", "--quiet", remoteRef)
+}
+
+// PushCarryNotes pushes the local carry notes to the openshift remote.
+func PushCarryNotes(streams genericclioptions.IOStreams, currInfo RepoInfo) error {
+	return RunCmd(streams, currInfo.Path, "git", "push", currInfo.Openshift.Name, CarryNotesRef+":"+CarryNotesRef)
+}
+
+// CarryNoteMatcher finds the note for a carry by commit, then by patch-id, then by subject, so that decisions follow a
+// carry through rebases.
+type CarryNoteMatcher struct {
+	bySHA     map[string]CarryNote
+	byPatchID map[string]CarryNote
+	bySubject map[string]CarryNote
+}
+
+// NewCarryNoteMatcher indexes the notes of the repo.  Notes on commits that aren't in the repo only match by commit.
+// Subjects shared by noted commits with different patches, like generic UPSTREAM: <drop>: ones, don't match at all, so
+// that unrelated carries don't get each other's decisions.
+func NewCarryNoteMatcher(repoPath string) (*CarryNoteMatcher, error) {
+	notes, err := ReadCarryNotes(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	ret := &CarryNoteMatcher{
+		bySHA:     notes,
+		byPatchID: map[string]CarryNote{},
+		bySubject: map[string]CarryNote{},
+	}
+
+	commits := []string{}
+	for commit := range notes {
+		commits = append(commits, commit)
+	}
+	sort.Strings(commits)
+	commits, err = existingCommits(repoPath, commits)
+	if err != nil {
+		return nil, err
+	}
+	carries, err := LoadCarries(repoPath, commits)
+	if err != nil {
+		return nil, err
+	}
+	// the same carry on several fork branches has one subject and patch-id, so only several patch-ids are ambiguous
+	subjectPatchIDs := map[string]map[string]bool{}
+	for _, carry := range carries {
+		if len(carry.PatchID) > 0 {
+			ret.byPatchID[carry.PatchID] = ret.bySHA[carry.SHA].Merge(ret.byPatchID[carry.PatchID])
+		}
+		subject := NormalizeSubject(carry.Subject)
+		if subjectPatchIDs[subject] == nil {
+			subjectPatchIDs[subject] = map[string]bool{}
+		}
+		subjectPatchIDs[subject][carry.PatchID] = true
+		ret.bySubject[subject] = ret.bySHA[carry.SHA].Merge(ret.bySubject[subject])
+	}
+	for subject, patchIDs := range subjectPatchIDs {
+		if len(patchIDs) > 1 {
+			delete(ret.bySubject, subject)
+		}
+	}
+	return ret, nil
+}
+
+// Find returns the note for a carry, if there is one.
+func (m *CarryNoteMatcher) Find(carry Carry) (CarryNote, bool) {
+	if note, ok := m.bySHA[carry.SHA]; ok {
+		return note, true
+	}
+	if note, ok := m.byPatchID[carry.PatchID]; ok && len(carry.PatchID) > 0 {
+		return note, true
+	}
+	note, ok := m.bySubject[NormalizeSubject(carry.Subject)]
+	return note, ok
+}
+
+// existingCommits filters out the commits that aren't in the repo.
+func existingCommits(repoPath string, commits []string) ([]string, error) {
+	if len(commits) == 0 {
+		return commits, nil
+	}
+	cmd := exec.Command("git", "cat-file", "--batch-check=%(objectname) %(objecttype)")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(strings.Join(commits, "\n") + "\n")
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("unable to check for commits: %v", err)
+	}
+
+	ret := []string{}
+	for _, line := range strings.Split(out.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == "commit" {
+			ret = append(ret, fields[0])
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,44 @@
+package kubefork
+
+import (
+	"regexp"
+)
+
+// Kinds of fork commits, from the UPSTREAM: prefix of their subject.
+const (
+	// CarryKindCarry is UPSTREAM: <carry>:, a change carried until it is upstream.
+	CarryKindCarry = "carry"
+	// CarryKindDrop is UPSTREAM: <drop>:, a change to drop on the next rebase, like generated files.
+	CarryKindDrop = "drop"
+	// CarryKindUpstream is UPSTREAM: 12345:, a pick of an upstream pull request.
+	CarryKindUpstream = "upstream"
+	// CarryKindUnknown is a commit without an UPSTREAM: prefix.
+	CarryKindUnknown = "unknown"
+)
+
+// CarrySubject is a fork commit subject broken into its parts.
+type CarrySubject struct {
+	Kind string
+	// PR is the upstream pull request number for CarryKindUpstream.
+	PR string
+	// Description is the subject without the UPSTREAM: prefix.
+	Description string
+}
+
+var carrySubjectRegex = regexp.MustCompile(`^UPSTREAM: (<carry>|<drop>|[0-9]+): (.*)$`)
+
+// ParseCarrySubject parses subjects like "UPSTREAM: <carry>: description" and "UPSTREAM: 12345: description".
+func ParseCarrySubject(subject string) CarrySubject {
+	matches := carrySubjectRegex.FindStringSubmatch(subject)
+	if matches == nil {
+		return CarrySubject{Kind: CarryKindUnknown, Description: subject}
+	}
+
+	switch matches[1] {
+	case "<carry>":
+		return CarrySubject{Kind: CarryKindCarry, Description: matches[2]}
+	case "<drop>":
+		return CarrySubject{Kind: CarryKindDrop, Description: matches[2]}
+	}
+	return CarrySubject{Kind: CarryKindUpstream, PR: matches[1], Description: matches[2]}
+}
@@ -0,0 +1,118 @@
+package kubefork
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+)
+
+// RefJournal records every ref a run created on a remote so that a partial run can be rolled back.  It is rewritten
+// after every entry so that it is accurate even when the run dies halfway.
+type RefJournal struct {
+	path string
+
+	Entries []RefJournalEntry `json:"entries"`
+}
+
+type RefJournalEntry struct {
+	Repo       string `json:"repo"`       // like kubernetes, apimachinery
+	RepoPath   string `json:"repoPath"`   // local clone the ref was pushed from
+	RemoteName string `json:"remoteName"` // like openshift
+	Ref        string `json:"ref"`        // like refs/heads/origin-4.3-kubernetes-1.16.0
+	SHA        string `json:"sha"`        // what the ref pointed to when we created it
+}
+
+// NewRefJournal creates an empty journal at journalPath, failing if one is already there.
+func NewRefJournal(journalPath string) (*RefJournal, error) {
+	if _, err := ioutil.ReadFile(journalPath); err == nil {
+		return nil, fmt.Errorf("journal %q already exists", journalPath)
+	}
+	journal := &RefJournal{path: journalPath, Entries: []RefJournalEntry{}}
+	if err := journal.save(); err != nil {
+		return nil, err
+	}
+	return journal, nil
+}
+
+func ReadRefJournal(journalPath string) (*RefJournal, error) {
+	content, err := ioutil.ReadFile(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	journal := &RefJournal{path: journalPath}
+	if err := json.Unmarshal(content, journal); err != nil {
+		return nil, fmt.Errorf("unable to read journal %q: %v", journalPath, err)
+	}
+	return journal, nil
+}
+
+func (j *RefJournal) Path() string {
+	return j.path
+}
+
+func (j *RefJournal) Record(entry RefJournalEntry) error {
+	j.Entries = append(j.Entries, entry)
+	return j.save()
+}
+
+func (j *RefJournal) save() error {
+	content, err := json.MarshalIndent(j, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(j.path, content, 0644)
+}
+
+// RecordBranch records that the local branchName is about to be pushed to remoteName.  Record before pushing, so that a
+// run that dies right after the push can still be rolled back.  Rollback skips refs that never made it.
+func (j *RefJournal) RecordBranch(currInfo RepoInfo, remoteName, branchName string) error {
+	sha, err := CollectCmdStdout(currInfo.Path, "git", "rev-parse", "--verify", "refs/heads/"+branchName)
+	if err != nil {
+		return err
+	}
+	return j.Record(RefJournalEntry{
+		Repo:       currInfo.UpstreamName,
+		RepoPath:   currInfo.Path,
+		RemoteName: remoteName,
+		Ref:        "refs/heads/" + branchName,
+		SHA:        strings.TrimSpace(sha),
+	})
+}
+
+// Rollback deletes every journaled ref from its remote, newest first.  A ref is only deleted if it still points to the
+// recorded SHA so that nothing pushed on top of it is destroyed.  Refs that moved are reported and left alone.
+func (j *RefJournal) Rollback(streams genericclioptions.IOStreams) error {
+	moved := []string{}
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		entry := j.Entries[i]
+
+		remoteRef, err := CollectCmdStdout(entry.RepoPath, "git", "ls-remote", entry.RemoteName, entry.Ref)
+		if err != nil {
+			return err
+		}
+		remoteFields := strings.Fields(remoteRef)
+		switch {
+		case len(remoteFields) == 0:
+			fmt.Fprintf(streams.Out, "For kubernetes/%v, %q is already gone from %q\n", entry.Repo, entry.Ref, entry.RemoteName)
+			continue
+		case remoteFields[0] != entry.SHA:
+			fmt.Fprintf(streams.Out, "For kubernetes/%v, %q moved from %v to %v, leaving it alone\n", entry.Repo, entry.Ref, entry.SHA, remoteFields[0])
+			moved = append(moved, entry.Repo+":"+entry.Ref)
+			continue
+		}
+
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, deleting %q from %q\n", entry.Repo, entry.Ref, entry.RemoteName)
+		// the lease makes the delete fail if the ref moves between our check and the push
+		if err := RunCmd(streams.Indent(), entry.RepoPath, "git", "push", "--force-with-lease="+entry.Ref+":"+entry.SHA, entry.RemoteName, ":"+entry.Ref); err != nil {
+			return err
+		}
+	}
+
+	if len(moved) > 0 {
+		return fmt.Errorf("refs moved since they were created and were not deleted: %v", moved)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package kubefork
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// KubernetesCommitTrailer is the trailer the publishing bot adds to every commit it publishes to a staging repo.  The
+// value is the kubernetes commit the staging commit was published from.
+const KubernetesCommitTrailer = "Kubernetes-commit"
+
+// KubernetesCommit returns the value of the last Kubernetes-commit trailer in a commit message, empty if there isn't one.
+func KubernetesCommit(message string) string {
+	ret := ""
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, KubernetesCommitTrailer+":") {
+			continue
+		}
+		ret = strings.TrimSpace(line[len(KubernetesCommitTrailer)+1:])
+	}
+	return ret
+}
+
+var trailerRegex = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+
+// AddKubernetesCommit appends a Kubernetes-commit trailer to a commit message, joining an existing trailer block if the
+// message ends with one.
+func AddKubernetesCommit(message, kubeCommit string) string {
+	message = strings.TrimRight(message, "\n")
+	paragraphs := strings.Split(message, "\n\n")
+	lastParagraph := paragraphs[len(paragraphs)-1]
+
+	inTrailers := len(paragraphs) > 1
+	for _, line := range strings.Split(lastParagraph, "\n") {
+		if !trailerRegex.MatchString(line) {
+			inTrailers = false
+		}
+	}
+	if inTrailers {
+		return fmt.Sprintf("%s\n%s: %s\n", message, KubernetesCommitTrailer, kubeCommit)
+	}
+	return fmt.Sprintf("%s\n\n%s: %s\n", message, KubernetesCommitTrailer, kubeCommit)
+}
+
+// StagingPath is where a staging repo lives inside of kubernetes.
+func StagingPath(upstreamName string) string {
+	return "staging/src/k8s.io/" + upstreamName
+}
+
+// StagingRepoForPath returns the staging repo a path in kubernetes belongs to, if any.
+func StagingRepoForPath(path string) (string, bool) {
+	prefix := StagingPath("")
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	name := strings.SplitN(strings.TrimPrefix(path, prefix), "/", 2)[0]
+	return name, len(name) > 0
+}
+
+// PublishingManifests are the files in a staging repo that publishing rewrites rather than copies from kubernetes.
+var PublishingManifests = []string{"go.mod", "go.sum", "Godeps/Godeps.json"}
+
+// StagingPathspec selects the staging repo in kubernetes, minus the files publishing rewrites.
+func StagingPathspec(upstreamName string) []string {
+	ret := []string{StagingPath(upstreamName)}
+	for _, manifest := range PublishingManifests {
+		ret = append(ret, ":(exclude)"+StagingPath(upstreamName)+"/"+manifest)
+	}
+	return ret
+}
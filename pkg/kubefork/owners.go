@@ -0,0 +1,173 @@
+package kubefork
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type teamPrefix struct {
+	prefix string
+	team   string
+}
+
+// OwnersResolver finds the teams that own paths, first from a path to team mapping and then from the approvers of the
+// nearest OWNERS file.
+type OwnersResolver struct {
+	teams []teamPrefix
+	// tree holds the OWNERS files, usually an upstream tag.
+	tree *object.Tree
+
+	approversByDir map[string][]string
+}
+
+// NewOwnersResolver reads the mapping file, which has a "path/prefix team" pair per line.  Blank lines and lines
+// starting with # are ignored.  An empty mappingFile uses only OWNERS files.
+func NewOwnersResolver(mappingFile string, tree *object.Tree) (*OwnersResolver, error) {
+	ret := &OwnersResolver{
+		tree:           tree,
+		approversByDir: map[string][]string{},
+	}
+	if len(mappingFile) == 0 {
+		return ret, nil
+	}
+
+	file, err := os.Open(mappingFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"path/prefix team\", got %q", mappingFile, lineNumber, line)
+		}
+		ret.teams = append(ret.teams, teamPrefix{prefix: strings.Trim(fields[0], "/"), team: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TeamForPath returns the team with the longest prefix matching the path.
+func (r *OwnersResolver) TeamForPath(file string) (string, bool) {
+	best := -1
+	for i, team := range r.teams {
+		if file != team.prefix && !strings.HasPrefix(file, team.prefix+"/") {
+			continue
+		}
+		if best < 0 || len(team.prefix) > len(r.teams[best].prefix) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	return r.teams[best].team, true
+}
+
+// Owners returns the teams owning the files.  If none of the files are in the mapping, it returns the approvers of the
+// nearest OWNERS files instead.
+func (r *OwnersResolver) Owners(files []string) ([]string, error) {
+	teams := map[string]bool{}
+	for _, file := range files {
+		if team, ok := r.TeamForPath(file); ok {
+			teams[team] = true
+		}
+	}
+	if len(teams) > 0 {
+		return sortedSet(teams), nil
+	}
+
+	approvers := map[string]bool{}
+	for _, file := range files {
+		fileApprovers, err := r.nearestApprovers(path.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+		for _, approver := range fileApprovers {
+			approvers[approver] = true
+		}
+	}
+	return sortedSet(approvers), nil
+}
+
+// nearestApprovers walks up from dir to the first OWNERS file with approvers.
+func (r *OwnersResolver) nearestApprovers(dir string) ([]string, error) {
+	if approvers, ok := r.approversByDir[dir]; ok {
+		return approvers, nil
+	}
+
+	approvers := []string{}
+	if r.tree != nil {
+		ownersFile := "OWNERS"
+		if dir != "." {
+			ownersFile = dir + "/OWNERS"
+		}
+		file, err := r.tree.File(ownersFile)
+		switch {
+		case err == object.ErrFileNotFound:
+		case err != nil:
+			return nil, err
+		default:
+			content, err := file.Contents()
+			if err != nil {
+				return nil, err
+			}
+			approvers = parseOwnersApprovers(content)
+		}
+	}
+	if len(approvers) == 0 && dir != "." {
+		var err error
+		approvers, err = r.nearestApprovers(path.Dir(dir))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.approversByDir[dir] = approvers
+	return approvers, nil
+}
+
+// parseOwnersApprovers reads the top-level approvers list of an OWNERS file.  This is just enough yaml for OWNERS files,
+// filters and aliases are not expanded.
+func parseOwnersApprovers(content string) []string {
+	ret := []string{}
+	inApprovers := false
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			inApprovers = strings.HasPrefix(trimmed, "approvers:")
+			continue
+		}
+		if inApprovers && strings.HasPrefix(trimmed, "- ") {
+			approver := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(trimmed, "- "), "#", 2)[0])
+			ret = append(ret, strings.Trim(approver, `"'`))
+		}
+	}
+	return ret
+}
+
+func sortedSet(set map[string]bool) []string {
+	ret := []string{}
+	for key := range set {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,109 @@
+package kubefork
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// PatchSeriesFile lists the patches of a patch directory in the order to apply them, like quilt.
+const PatchSeriesFile = "series"
+
+// SeriesPatch is a patch listed in a series file.
+type SeriesPatch struct {
+	Path string
+	// Commit is the commit the patch was made from, if it is a git format-patch mbox.
+	Commit string
+	// SquashInto is the commit this patch should be folded into, from the comment above it.
+	SquashInto string
+}
+
+var (
+	squashCommentRegex = regexp.MustCompile(`^#\s*([0-9a-f]+) squashes into ([0-9a-f]+)$`)
+	mboxFromRegex      = regexp.MustCompile(`^From ([0-9a-f]{40}) `)
+)
+
+// WritePatchSeries writes each commit as a git format-patch mbox into dir, numbered in order, and a series file that
+// lists them.  Commits in squashInto get a comment above their patch naming the commit to fold them into.
+func WritePatchSeries(repoPath, dir string, commits []string, squashInto map[string]string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	series := []string{}
+	for i, commit := range commits {
+		out, err := CollectCmdStdout(repoPath, "git", "format-patch", "-1", "--binary", "--keep-subject", "--no-signature",
+			fmt.Sprintf("--start-number=%d", i+1), "--output-directory="+absDir, commit)
+		if err != nil {
+			return fmt.Errorf("unable to format %v: %v", commit, err)
+		}
+		if target := squashInto[commit]; len(target) > 0 {
+			series = append(series, fmt.Sprintf("# %s squashes into %s", commit, target))
+		}
+		series = append(series, filepath.Base(strings.TrimSpace(out)))
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, PatchSeriesFile), []byte(strings.Join(series, "\n")+"\n"), 0644)
+}
+
+// ReadPatchSeries returns the patches listed in the series file of dir, in order.  Blank lines, comments other than
+// squashes, and quilt patch options after the file name are ignored.
+func ReadPatchSeries(dir string) ([]SeriesPatch, error) {
+	file, err := os.Open(filepath.Join(dir, PatchSeriesFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	ret := []SeriesPatch{}
+	squashInto := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if matches := squashCommentRegex.FindStringSubmatch(line); matches != nil {
+			squashInto = matches[2]
+			continue
+		}
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patch := SeriesPatch{
+			Path:       filepath.Join(dir, strings.Fields(line)[0]),
+			SquashInto: squashInto,
+		}
+		squashInto = ""
+		if patch.Commit, err = patchCommit(patch.Path); err != nil {
+			return nil, err
+		}
+		ret = append(ret, patch)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// patchCommit reads the commit from the first line of a git format-patch mbox.
+func patchCommit(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	firstLine, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && len(firstLine) == 0 {
+		return "", nil
+	}
+	if matches := mboxFromRegex.FindStringSubmatch(firstLine); matches != nil {
+		return matches[1], nil
+	}
+	return "", nil
+}
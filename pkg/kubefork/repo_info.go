@@ -42,6 +42,7 @@ func GetAllKubeRepos(streams genericclioptions.IOStreams, kubeHome string) ([]Re
 				URLs: []string{fmt.Sprintf(`git@github.com:/openshift/%s.git`, "kubernetes")},
 				Fetch: []config.RefSpec{
 					"+refs/heads/*:refs/remotes/openshift/*",
+					"+refs/notes/*:refs/notes/remotes/openshift/*",
 				},
 			},
 		},
@@ -130,6 +131,7 @@ func GetRepoInfoForStaging(streams genericclioptions.IOStreams, currInfo RepoInf
 					URLs: []string{fmt.Sprintf(`git@github.com:/openshift/%s.git`, "kubernetes-"+repoName)},
 					Fetch: []config.RefSpec{
 						"+refs/heads/*:refs/remotes/openshift/*",
+						"+refs/notes/*:refs/notes/remotes/openshift/*",
 					},
 				},
 			},
@@ -163,6 +165,9 @@ func FetchUpdates(streams genericclioptions.IOStreams, currInfo RepoInfo) (*git.
 	if err := fetch(streams.Out, openshiftRemote, currInfo.UpstreamName, currInfo.Openshift); err != nil {
 		return nil, nil, err
 	}
+	if err := MergeCarryNotes(streams.Indent(), currInfo); err != nil {
+		return nil, nil, err
+	}
 
 	return upstreamRemote, openshiftRemote, nil
 }
@@ -250,10 +255,10 @@ func CloneRepo(streams genericclioptions.IOStreams, currInfo RepoInfo) error {
 	_, err := os.Stat(currInfo.Path)
 	switch {
 	case err == nil:
-		fmt.Printf("Found kubernetes/%v in %q, skipping clone\n", currInfo.UpstreamName, currInfo.Path)
+		fmt.Fprintf(streams.Out, "Found kubernetes/%v in %q, skipping clone\n", currInfo.UpstreamName, currInfo.Path)
 		return nil
 	case os.IsNotExist(err):
-		fmt.Printf("Missing kubernetes/%v in %q, cloning \n", currInfo.UpstreamName, currInfo.Path)
+		fmt.Fprintf(streams.Out, "Missing kubernetes/%v in %q, cloning \n", currInfo.UpstreamName, currInfo.Path)
 	case err != nil:
 		return err
 	}
@@ -268,3 +273,41 @@ func CloneRepo(streams genericclioptions.IOStreams, currInfo RepoInfo) error {
 	}
 	return nil
 }
+
+// ReposForKubeVersion filters repoInfos down to kubernetes and the staging repos it had at kubeVersion.  Staging repos
+// that existed at kubeVersion but are not present in repoInfos are returned as errors because we can't check them.
+func ReposForKubeVersion(repoInfos []RepoInfo, kubeVersion string) ([]RepoInfo, error) {
+	var kubeRepoInfo *RepoInfo
+	for i := range repoInfos {
+		if repoInfos[i].UpstreamName == "kubernetes" {
+			kubeRepoInfo = &repoInfos[i]
+		}
+	}
+	if kubeRepoInfo == nil {
+		return nil, fmt.Errorf("missing kubernetes repo")
+	}
+	kubeRepo, err := git.PlainOpen(kubeRepoInfo.Path)
+	if err != nil {
+		return nil, err
+	}
+	stagingRepos, err := StagingRepoNames(kubeRepo, kubeVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []RepoInfo{*kubeRepoInfo}
+	for _, stagingRepo := range stagingRepos {
+		found := false
+		for _, currInfo := range repoInfos {
+			if currInfo.UpstreamName == stagingRepo {
+				ret = append(ret, currInfo)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("kubernetes %v has staging repo %q, but it is not in staging on master", kubeVersion, stagingRepo)
+		}
+	}
+	return ret, nil
+}
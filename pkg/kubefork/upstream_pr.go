@@ -0,0 +1,98 @@
+package kubefork
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// UpstreamPRCommits finds the merge commit of an upstream kubernetes pull request and the non-merge commits it brought
+// in, oldest first.
+func UpstreamPRCommits(kubePath, pr string) (string, []string, error) {
+	merge, err := CollectCmdStdout(kubePath, "git", "log", "-1", "--merges", "--format=%H", "--remotes=upstream",
+		"--grep=^Merge pull request #"+pr+" from")
+	if err != nil {
+		return "", nil, err
+	}
+	merge = strings.TrimSpace(merge)
+	if len(merge) == 0 {
+		return "", nil, fmt.Errorf("no merge of upstream pull request #%s", pr)
+	}
+
+	commits, err := CollectCmdStdout(kubePath, "git", "rev-list", "--reverse", "--no-merges", merge+"^1.."+merge+"^2")
+	if err != nil {
+		return "", nil, err
+	}
+	return merge, strings.Fields(commits), nil
+}
+
+// ResolveCommits expands a commit or a range like a..b into full SHAs, oldest first.
+func ResolveCommits(repoPath, commitOrRange string) ([]string, error) {
+	if strings.Contains(commitOrRange, "..") {
+		commits, err := CollectCmdStdout(repoPath, "git", "rev-list", "--reverse", "--no-merges", commitOrRange)
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve %q: %v", commitOrRange, err)
+		}
+		return strings.Fields(commits), nil
+	}
+
+	commit, err := CollectCmdStdout(repoPath, "git", "rev-parse", "--verify", commitOrRange+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %q: %v", commitOrRange, err)
+	}
+	return []string{strings.TrimSpace(commit)}, nil
+}
+
+// CommitsTouching filters commits down to the ones that change something under subdir.
+func CommitsTouching(repoPath string, commits []string, subdir string) ([]string, error) {
+	ret := []string{}
+	for _, commit := range commits {
+		files, err := CollectCmdStdout(repoPath, "git", "diff-tree", "--no-commit-id", "--name-only", "-r", commit, "--", subdir)
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(files)) > 0 {
+			ret = append(ret, commit)
+		}
+	}
+	return ret, nil
+}
+
+// IsAncestor is true when commit is in the history of ref.
+func IsAncestor(repoPath, commit, ref string) bool {
+	_, err := CollectCmdStdout(repoPath, "git", "merge-base", "--is-ancestor", commit, ref)
+	return err == nil
+}
+
+var mergePRRegex = regexp.MustCompile(`^Merge pull request #([0-9]+) from `)
+
+// UpstreamPRForCommit finds the upstream kubernetes pull request that merged a commit into upstream master.
+func UpstreamPRForCommit(kubePath, commit string) (string, error) {
+	merges, err := CollectCmdStdout(kubePath, "git", "log", "--merges", "--ancestry-path", "--reverse", "--format=%H%x00%s", commit+"..upstream/master")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(merges, "\n") {
+		parts := strings.SplitN(line, "\x00", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		matches := mergePRRegex.FindStringSubmatch(parts[1])
+		if matches == nil {
+			continue
+		}
+		// the first pull request merge that brought the commit in from its second parent
+		if IsAncestor(kubePath, commit, parts[0]+"^2") {
+			return matches[1], nil
+		}
+	}
+	return "", fmt.Errorf("no upstream pull request merged %v into upstream/master", commit)
+}
+
+// UpstreamSubject is the OpenShift convention for the subject of a picked upstream commit.
+func UpstreamSubject(pr, subject string) string {
+	if strings.HasPrefix(subject, "UPSTREAM: ") {
+		return subject
+	}
+	return fmt.Sprintf("UPSTREAM: %s: %s", pr, subject)
+}
@@ -0,0 +1,201 @@
+package listforkbranches
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type ListForkBranchesOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+	Output   string // table or json
+}
+
+// ForkBranchStatus describes one fork branch across every repo.
+type ForkBranchStatus struct {
+	Branch      string `json:"branch"`
+	ForkOwner   string `json:"forkOwner"`
+	ForkVersion string `json:"forkVersion"`
+	KubeVersion string `json:"kubeVersion"`
+
+	// Complete is true when every repo that should have the branch has it.
+	Complete     bool             `json:"complete"`
+	MissingRepos []string         `json:"missingRepos,omitempty"`
+	Repos        []RepoBranchInfo `json:"repos"`
+}
+
+// RepoBranchInfo describes one fork branch in one repo.
+type RepoBranchInfo struct {
+	Repo     string `json:"repo"`
+	Expected bool   `json:"expected"`
+	Present  bool   `json:"present"`
+	// Carries is the number of commits over the upstream tag, nil if the branch is missing or they couldn't be counted.
+	Carries *int   `json:"carries,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NewListForkBranchesOptions(streams genericclioptions.IOStreams) *ListForkBranchesOptions {
+	return &ListForkBranchesOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+		Output:   "table",
+	}
+}
+
+// NewCmdListForkBranches lists every openshift fork branch across kubernetes and the staging repos.
+func NewCmdListForkBranches(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewListForkBranchesOptions(streams)
+	cmd := &cobra.Command{
+		Use: "list-fork-branches --kube-home=/path/to/k8s.io --output=table",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Every <owner>-<version>-kubernetes-<kube-version> branch found on an openshift remote is listed with its carry count
+and whether it exists in every repo that was in kubernetes/staging at that kube version.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Output, "output", o.Output, "output format, table or json")
+
+	return cmd
+}
+
+func (o *ListForkBranchesOptions) Run() error {
+	if o.Output != "table" && o.Output != "json" {
+		return fmt.Errorf("output must be table or json, not %q", o.Output)
+	}
+
+	// progress goes to stderr so that the listing itself can be piped
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+
+	repos := map[string]*git.Repository{}
+	branchesByRepo := map[string]map[string]bool{}
+	allBranches := map[string]kubefork.ForkBranchInfo{}
+	for _, currInfo := range repoInfos {
+		if err := kubefork.CloneRepo(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		repo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		forkBranches, err := kubefork.FindOpenShiftForkBranches(repo)
+		if err != nil {
+			return err
+		}
+		repos[currInfo.UpstreamName] = repo
+		branchesByRepo[currInfo.UpstreamName] = map[string]bool{}
+		for _, forkBranch := range forkBranches {
+			branchesByRepo[currInfo.UpstreamName][forkBranch.BranchName()] = true
+			allBranches[forkBranch.BranchName()] = forkBranch
+		}
+	}
+
+	sortedBranches := []kubefork.ForkBranchInfo{}
+	for _, forkBranch := range allBranches {
+		sortedBranches = append(sortedBranches, forkBranch)
+	}
+	kubefork.SortForkBranches(sortedBranches)
+
+	statuses := []ForkBranchStatus{}
+	for _, forkBranch := range sortedBranches {
+		expectedRepos := map[string]bool{"kubernetes": true}
+		stagingRepos, err := kubefork.StagingRepoNames(repos["kubernetes"], forkBranch.KubeVersion)
+		if err != nil {
+			fmt.Fprintf(progressStreams.ErrOut, "For %v, unable to determine staging repos: %v\n", forkBranch.BranchName(), err)
+		}
+		for _, stagingRepo := range stagingRepos {
+			expectedRepos[stagingRepo] = true
+		}
+
+		status := ForkBranchStatus{
+			Branch:      forkBranch.BranchName(),
+			ForkOwner:   forkBranch.ForkOwner,
+			ForkVersion: forkBranch.ForkVersion,
+			KubeVersion: forkBranch.KubeVersion,
+			Complete:    true,
+		}
+		for _, currInfo := range repoInfos {
+			repoStatus := RepoBranchInfo{
+				Repo:     currInfo.UpstreamName,
+				Expected: expectedRepos[currInfo.UpstreamName],
+				Present:  branchesByRepo[currInfo.UpstreamName][forkBranch.BranchName()],
+			}
+			if !repoStatus.Present && !repoStatus.Expected {
+				continue
+			}
+			if !repoStatus.Present {
+				status.Complete = false
+				status.MissingRepos = append(status.MissingRepos, currInfo.UpstreamName)
+			} else {
+				carries, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, forkBranch)
+				if err != nil {
+					repoStatus.Error = err.Error()
+				} else {
+					count := len(carries)
+					repoStatus.Carries = &count
+				}
+			}
+			status.Repos = append(status.Repos, repoStatus)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if o.Output == "json" {
+		encoder := json.NewEncoder(o.Streams.Out)
+		encoder.SetIndent("", "    ")
+		return encoder.Encode(statuses)
+	}
+	return printTable(o.Streams, statuses)
+}
+
+func printTable(streams genericclioptions.IOStreams, statuses []ForkBranchStatus) error {
+	w := tabwriter.NewWriter(streams.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BRANCH\tOWNER\tFORK-VERSION\tKUBE-VERSION\tKUBE-CARRIES\tREPOS\tMISSING")
+	for _, status := range statuses {
+		kubeCarries := "-"
+		present := 0
+		for _, repoStatus := range status.Repos {
+			if repoStatus.Present {
+				present++
+			}
+			if repoStatus.Repo == "kubernetes" && repoStatus.Carries != nil {
+				kubeCarries = strconv.Itoa(*repoStatus.Carries)
+			}
+		}
+		missing := "-"
+		if len(status.MissingRepos) > 0 {
+			missing = strings.Join(status.MissingRepos, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d/%d\t%s\n",
+			status.Branch, status.ForkOwner, status.ForkVersion, status.KubeVersion, kubeCarries, present, len(status.Repos), missing)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,111 @@
+package lookupkubecommit
+
+import (
+	"fmt"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubecommitindex"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+)
+
+type LookupKubeCommitOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Repo          string // like apimachinery
+	KubeCommit    string
+	StagingCommit string
+}
+
+func NewLookupKubeCommitOptions(streams genericclioptions.IOStreams) *LookupKubeCommitOptions {
+	return &LookupKubeCommitOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+	}
+}
+
+// NewCmdLookupKubeCommit maps between kubernetes commits and staging repo commits.
+func NewCmdLookupKubeCommit(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewLookupKubeCommitOptions(streams)
+	cmd := &cobra.Command{
+		Use: "lookup-kube-commit --kube-home=/path/to/k8s.io --repo=apimachinery (--kube-commit=<sha> | --staging-commit=<sha>)",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Staging commits are mapped to kubernetes commits by their Kubernetes-commit trailer.  The mapping is kept under
+--kube-home and only new history is scanned on each run.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "staging repo, like apimachinery, client-go")
+	cmd.Flags().StringVar(&o.KubeCommit, "kube-commit", o.KubeCommit, "full kubernetes commit to find the staging commits for")
+	cmd.Flags().StringVar(&o.StagingCommit, "staging-commit", o.StagingCommit, "full staging commit to find the kubernetes commit for")
+
+	return cmd
+}
+
+func (o *LookupKubeCommitOptions) Run() error {
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if o.Repo == "kubernetes" {
+		return fmt.Errorf("repo must be a staging repo")
+	}
+	if (len(o.KubeCommit) == 0) == (len(o.StagingCommit) == 0) {
+		return fmt.Errorf("must have exactly one of kube-commit or staging-commit")
+	}
+
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		index, err := kubecommitindex.ForRepo(progressStreams.Indent(), o.KubeHome, currInfo)
+		if err != nil {
+			return err
+		}
+
+		if len(o.KubeCommit) > 0 {
+			stagingCommits := index.StagingCommits(o.KubeCommit)
+			if len(stagingCommits) == 0 {
+				return fmt.Errorf("kubernetes commit %v was not published to %v", o.KubeCommit, o.Repo)
+			}
+			for _, stagingCommit := range stagingCommits {
+				fmt.Fprintln(o.Streams.Out, stagingCommit)
+			}
+			return nil
+		}
+
+		kubeCommit := index.KubeCommit(o.StagingCommit)
+		if len(kubeCommit) == 0 {
+			return fmt.Errorf("%v commit %v was not published from kubernetes", o.Repo, o.StagingCommit)
+		}
+		fmt.Fprintln(o.Streams.Out, kubeCommit)
+		return nil
+	}
+
+	return fmt.Errorf("%q is not a staging repo", o.Repo)
+}
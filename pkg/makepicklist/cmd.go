@@ -1,9 +1,7 @@
 package makepicklist
 
 import (
-	"encoding/csv"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
@@ -26,6 +24,17 @@ type MakePickListOptions struct {
 	KubeVersion         string // like 1.15.0
 	PreviousForkVersion string // like 4.1
 	PreviousKubeVersion string // like 1.14.3
+
+	TrialApply bool
+	Metadata   bool
+
+	Owners      bool
+	TeamMapping string
+
+	Dependencies bool
+	DOTFile      string
+
+	PatchDir string
 }
 
 func NewCreateKubeBranchesForOriginOptions(streams genericclioptions.IOStreams) *MakePickListOptions {
@@ -46,6 +55,31 @@ func NewCmdCreateKubeBranchesForOriginOptions(streams genericclioptions.IOStream
 This command will auto-create it if necessary and auto-create the repos inside of it.
  1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
  2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Every carry gets a suggested-action of pick, drop, or squash.  A carry and its revert are both marked drop.  fixup! and
+squash! commits are grouped under the carry they squash into.  related-commit names the other side of the pair.
+
+--trial-apply cherry-picks the carries in order onto --kube-version and fills in conflict-status and conflicting-files.
+A conflict-after-failed-pick only touches files of earlier carries that failed, and may go away once those are fixed.
+Carries git refuses to pick at all, like merges, are not-applicable.
+
+Decisions recorded by record-carry-decisions as git notes in refs/notes/carries pre-fill suggested-action, upstream-pr,
+owner, and rationale.  Notes are matched to carries by commit, then by patch-id, then by subject if no other noted
+carry has the same subject.
+
+--dependencies adds a group and a depends-on column.  A carry depends on the carries that introduced the lines it
+changes, found by blame.  Carries connected by dependencies share a group.  A warning is printed for every dropped
+carry that kept carries depend on.  --dot-file writes the graph for graphviz.
+
+--patch-dir writes every carry that isn't dropped as a git format-patch mbox, numbered in pick list order, with a
+quilt-style series file.  apply-patch-series applies such a directory onto a fork branch.  validate-pick-list
+--patch-dir writes the same from a reviewed pick list.
+
+--metadata adds the author, dates, size, top-level directories, and staging repos touched by each carry.
+
+--owners adds an owner column with the approvers of the OWNERS files in --kube-version nearest to the files a carry
+touches.  --team-mapping is a file of "path/prefix team" lines.  The team with the longest matching prefix owns a file,
+and OWNERS files are only used for carries with no mapped files.  A per-team summary of the carries is printed.
 `,
 		Run: func(cmd *cobra.Command, args []string) {
 			if err := o.Run(); err != nil {
@@ -62,6 +96,13 @@ This command will auto-create it if necessary and auto-create the repos inside o
 	cmd.Flags().StringVar(&o.PreviousForkVersion, "previous-fork-version", o.PreviousForkVersion, "previous fork version to pull a picklist from, like 4.1, 4.2")
 	cmd.Flags().StringVar(&o.PreviousKubeVersion, "previous-kube-version", o.PreviousKubeVersion, "previous kube version to pull a picklist from, like 1.14.1, 1.15.0")
 	cmd.Flags().StringVar(&o.OutFile, "out-file", o.OutFile, "csv file to write to")
+	cmd.Flags().BoolVar(&o.Metadata, "metadata", o.Metadata, "add author, date, size, directory, and staging repo columns")
+	cmd.Flags().BoolVar(&o.Owners, "owners", o.Owners, "add an owner column from the approvers of the nearest upstream OWNERS file")
+	cmd.Flags().StringVar(&o.TeamMapping, "team-mapping", o.TeamMapping, "file of \"path/prefix team\" lines to fill the owner column from before falling back to OWNERS files")
+	cmd.Flags().BoolVar(&o.Dependencies, "dependencies", o.Dependencies, "add group and depends-on columns from the lines each carry changes")
+	cmd.Flags().StringVar(&o.DOTFile, "dot-file", o.DOTFile, "file to write the carry dependency graph to in graphviz dot format")
+	cmd.Flags().StringVar(&o.PatchDir, "patch-dir", o.PatchDir, "directory to write the carries that aren't dropped to as a patch series")
+	cmd.Flags().BoolVar(&o.TrialApply, "trial-apply", o.TrialApply, "cherry-pick every carry onto the new upstream tag in a temporary worktree and record the conflicts")
 
 	return cmd
 }
@@ -122,47 +163,123 @@ func (o *MakePickListOptions) Run() error {
 }
 
 func (o *MakePickListOptions) pickList(streams genericclioptions.IOStreams, repo *git.Repository, repoPath string) error {
-	prevStartingTag := kubefork.UpstreamTag(o.Repo, o.PreviousKubeVersion)
-	prevBranch := kubefork.NewForkBranch(o.ForkOwner, o.PreviousForkVersion, o.PreviousKubeVersion).BranchName()
-	//startingTag := kubefork.UpstreamTag(o.Repo, o.KubeVersion)
+	prevBranch := kubefork.NewForkBranch(o.ForkOwner, o.PreviousForkVersion, o.PreviousKubeVersion)
+	startingTag := kubefork.UpstreamTag(o.Repo, o.KubeVersion)
 	//destBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion).BranchName()
 
-	commits, err := kubefork.CollectCmdStdout(repoPath, "git", "rev-list", prevStartingTag+".."+prevBranch, "--no-merges", "--reverse")
-	if err != nil {
-		return err
-	}
-
-	outfile, err := os.Create(o.OutFile)
+	commits, err := kubefork.ListCarries(repoPath, o.Repo, prevBranch)
 	if err != nil {
 		return err
 	}
 
-	csvWriter := csv.NewWriter(outfile)
-	csvWriter.Write([]string{"description", "fork-commit", "upstream-commit", "upstream-on-release-commit"})
-
-	for _, commit := range strings.Split(commits, "\n") {
-		if len(commit) == 0 {
-			continue
+	var owners *kubefork.OwnersResolver
+	if o.ownersEnabled() {
+		// upstream OWNERS files of the version being rebased onto
+		tagRef, err := kubefork.FindKubeTag(startingTag, repo)
+		if err != nil {
+			return err
 		}
+		tagCommit, err := kubefork.ReferenceCommit(repo, tagRef)
+		if err != nil {
+			return err
+		}
+		tree, err := tagCommit.Tree()
+		if err != nil {
+			return err
+		}
+		if owners, err = kubefork.NewOwnersResolver(o.TeamMapping, tree); err != nil {
+			return err
+		}
+	}
 
-		record := []string{}
-
+	rows := []PickListRow{}
+	for _, commit := range commits {
 		commitUncastObj, err := repo.Object(plumbing.CommitObject, plumbing.NewHash(commit))
 		if err != nil {
 			return err
 		}
 		commitObj := commitUncastObj.(*object.Commit)
-		record = append(record, strings.Split(commitObj.Message, "\n")[0])
-
-		record = append(record, commit)
+		row := PickListRow{
+			Description: strings.Split(commitObj.Message, "\n")[0],
+			ForkCommit:  commit,
+			message:     commitObj.Message,
+		}
+		if o.Metadata || o.ownersEnabled() {
+			stats, err := commitObj.Stats()
+			if err != nil {
+				return err
+			}
+			if o.Metadata {
+				addMetadata(&row, commitObj, stats)
+			}
+			if o.ownersEnabled() {
+				files := []string{}
+				for _, stat := range stats {
+					files = append(files, stat.Name)
+				}
+				if row.Owner, err = owners.Owners(files); err != nil {
+					return err
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	rows = findRelated(rows)
+	if err := prefillFromNotes(repoPath, commits, rows); err != nil {
+		return err
+	}
+	if o.dependenciesEnabled() {
+		if err := o.addDependencies(streams, repoPath, prevBranch, rows); err != nil {
+			return err
+		}
+	}
+	if o.TrialApply {
+		if err := trialApply(streams, repoPath, startingTag, rows); err != nil {
+			return err
+		}
+	}
 
-		if err := csvWriter.Write(record); err != nil {
+	if err := WritePickList(o.OutFile, o.Columns(), rows); err != nil {
+		return err
+	}
+	if len(o.PatchDir) > 0 {
+		if err := WritePatchSeries(streams, repoPath, o.PatchDir, rows); err != nil {
 			return err
 		}
-		csvWriter.Flush()
 	}
 
-	outfile.Close()
+	if o.ownersEnabled() {
+		if err := writeTeamSummary(streams.Out, rows); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
+
+func (o *MakePickListOptions) dependenciesEnabled() bool {
+	return o.Dependencies || len(o.DOTFile) > 0
+}
+
+func (o *MakePickListOptions) ownersEnabled() bool {
+	return o.Owners || len(o.TeamMapping) > 0
+}
+
+// Columns is the pick list header for the optional columns requested.
+func (o *MakePickListOptions) Columns() []string {
+	columns := append([]string{}, PickListHeader...)
+	if o.TrialApply {
+		columns = append(columns, ColumnConflictStatus, ColumnConflictingFiles)
+	}
+	if o.Metadata {
+		columns = append(columns, ColumnAuthor, ColumnAuthorDate, ColumnCommitterDate, ColumnFilesTouched, ColumnLinesAdded,
+			ColumnLinesRemoved, ColumnDirectories, ColumnStagingRepos)
+	}
+	if o.ownersEnabled() {
+		columns = append(columns, ColumnOwner)
+	}
+	if o.dependenciesEnabled() {
+		columns = append(columns, ColumnGroup, ColumnDependsOn)
+	}
+	return columns
+}
@@ -0,0 +1,83 @@
+package makepicklist
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/carrydeps"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// addDependencies fills in the group and depends-on columns, warns about dropped carries that kept carries build on,
+// and writes the dot file if requested.
+func (o *MakePickListOptions) addDependencies(streams genericclioptions.IOStreams, repoPath string, prevBranch kubefork.ForkBranchInfo, rows []PickListRow) error {
+	fmt.Fprintf(streams.Out, "Finding dependencies between %d carries\n", len(rows))
+	since, err := kubefork.MergedUpstreamTag(repoPath, o.Repo, kubefork.OpenShiftBranchRef(prevBranch))
+	if err != nil {
+		return err
+	}
+	carries := []string{}
+	for _, row := range rows {
+		carries = append(carries, row.ForkCommit)
+	}
+	graph, err := carrydeps.Build(repoPath, since, carries)
+	if err != nil {
+		return err
+	}
+
+	groups := graph.Groups()
+	for i := range rows {
+		rows[i].Group = groups[rows[i].ForkCommit]
+		rows[i].DependsOn = graph.DependsOn[rows[i].ForkCommit]
+	}
+
+	WriteDependencyWarnings(streams, graph, rows)
+
+	if len(o.DOTFile) == 0 {
+		return nil
+	}
+	subjects := map[string]string{}
+	for _, row := range rows {
+		subjects[row.ForkCommit] = row.Description
+	}
+	dotFile, err := os.Create(o.DOTFile)
+	if err != nil {
+		return err
+	}
+	defer dotFile.Close()
+	if err := graph.WriteDOT(dotFile, subjects, droppedCarries(rows)); err != nil {
+		return err
+	}
+	return dotFile.Close()
+}
+
+// WriteDependencyWarnings warns about every dropped carry that kept carries depend on.  It returns how many there are.
+func WriteDependencyWarnings(streams genericclioptions.IOStreams, graph *carrydeps.Graph, rows []PickListRow) int {
+	subjects := map[string]string{}
+	for _, row := range rows {
+		subjects[row.ForkCommit] = row.Description
+	}
+	droppedWithDependents := graph.DroppedWithKeptDependents(droppedCarries(rows))
+	for _, carry := range graph.Carries {
+		dependents, ok := droppedWithDependents[carry]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(streams.ErrOut, "WARNING: %s %q is dropped, but these kept carries depend on it:\n", carry, subjects[carry])
+		for _, dependent := range dependents {
+			fmt.Fprintf(streams.Indent().ErrOut, "%s %q\n", dependent, subjects[dependent])
+		}
+	}
+	return len(droppedWithDependents)
+}
+
+func droppedCarries(rows []PickListRow) map[string]bool {
+	ret := map[string]bool{}
+	for _, row := range rows {
+		if row.SuggestedAction == ActionDrop {
+			ret[row.ForkCommit] = true
+		}
+	}
+	return ret
+}
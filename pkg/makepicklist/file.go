@@ -0,0 +1,82 @@
+package makepicklist
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadPickList reads a pick list written by make-pick-list and possibly edited since.  Columns are found by header, so
+// reordered and added columns are fine, but the header row and the fork-commit column are required.
+func ReadPickList(filename string) ([]string, []PickListRow, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// spreadsheets drop trailing empty cells
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s: empty pick list", filename)
+	}
+
+	header := []string{}
+	for _, column := range records[0] {
+		header = append(header, strings.TrimSpace(column))
+	}
+	hasForkCommit := false
+	for _, column := range header {
+		if column == ColumnForkCommit {
+			hasForkCommit = true
+		}
+	}
+	if !hasForkCommit {
+		return nil, nil, fmt.Errorf("%s: missing header row with a %q column", filename, ColumnForkCommit)
+	}
+
+	rows := []PickListRow{}
+	for i, record := range records[1:] {
+		row := PickListRow{}
+		for j, value := range record {
+			if j >= len(header) {
+				return nil, nil, fmt.Errorf("%s:%d: more values than columns", filename, i+2)
+			}
+			if err := row.SetValue(header[j], strings.TrimSpace(value)); err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %v", filename, i+2, err)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return header, rows, nil
+}
+
+// WritePickList writes the columns of rows as csv.
+func WritePickList(filename string, columns []string, rows []PickListRow) error {
+	outfile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+
+	csvWriter := csv.NewWriter(outfile)
+	if err := csvWriter.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := csvWriter.Write(row.Record(columns)); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return err
+	}
+	return outfile.Close()
+}
@@ -0,0 +1,55 @@
+package makepicklist
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// addMetadata fills in who wrote a carry and what it touches.
+func addMetadata(row *PickListRow, commit *object.Commit, stats object.FileStats) {
+	row.Author = commit.Author.Name + " <" + commit.Author.Email + ">"
+	row.AuthorDate = commit.Author.When.UTC().Format(time.RFC3339)
+	row.CommitterDate = commit.Committer.When.UTC().Format(time.RFC3339)
+
+	directories := map[string]bool{}
+	stagingRepos := map[string]bool{}
+	for _, stat := range stats {
+		row.FilesTouched++
+		row.LinesAdded += stat.Addition
+		row.LinesRemoved += stat.Deletion
+
+		directories[topLevelDirectory(stat.Name)] = true
+		if stagingRepo, ok := kubefork.StagingRepoForPath(stat.Name); ok {
+			stagingRepos[stagingRepo] = true
+		}
+	}
+	row.Directories = sortedKeys(directories)
+	row.StagingRepos = sortedKeys(stagingRepos)
+}
+
+// topLevelDirectory is the first two directories of a path, like pkg/kubelet, or the whole staging repo, like
+// staging/src/k8s.io/apiserver.
+func topLevelDirectory(file string) string {
+	if stagingRepo, ok := kubefork.StagingRepoForPath(file); ok {
+		return kubefork.StagingPath(stagingRepo)
+	}
+	parts := strings.Split(path.Dir(file), "/")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, "/")
+}
+
+func sortedKeys(set map[string]bool) []string {
+	ret := []string{}
+	for key := range set {
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret
+}
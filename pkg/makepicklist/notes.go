@@ -0,0 +1,45 @@
+package makepicklist
+
+import (
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// prefillFromNotes fills in the decisions recorded for carries in earlier reviews.  A recorded decision replaces the
+// suggested action.  Carries without a recorded upstream pull request get the one from their subject.
+func prefillFromNotes(repoPath string, commits []string, rows []PickListRow) error {
+	matcher, err := kubefork.NewCarryNoteMatcher(repoPath)
+	if err != nil {
+		return err
+	}
+	carries, err := kubefork.LoadCarries(repoPath, commits)
+	if err != nil {
+		return err
+	}
+	carriesBySHA := map[string]kubefork.Carry{}
+	for _, carry := range carries {
+		carriesBySHA[carry.SHA] = carry
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		row.UpstreamPR = kubefork.ParseCarrySubject(row.Description).PR
+
+		note, ok := matcher.Find(carriesBySHA[row.ForkCommit])
+		if !ok {
+			continue
+		}
+		if len(note.Decision) > 0 {
+			row.SuggestedAction = note.Decision
+		}
+		if len(note.UpstreamPR) > 0 {
+			row.UpstreamPR = note.UpstreamPR
+		}
+		if len(note.Owner) > 0 {
+			row.Owner = strings.Fields(note.Owner)
+		}
+		row.Rationale = note.Rationale
+	}
+	return nil
+}
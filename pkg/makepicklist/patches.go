@@ -0,0 +1,27 @@
+package makepicklist
+
+import (
+	"fmt"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// WritePatchSeries exports the rows that aren't dropped, in pick list order.  Squashes are noted in the series file
+// so that apply-patch-series folds them in.
+func WritePatchSeries(streams genericclioptions.IOStreams, repoPath, dir string, rows []PickListRow) error {
+	commits := []string{}
+	squashInto := map[string]string{}
+	for _, row := range rows {
+		if row.SuggestedAction == ActionDrop {
+			continue
+		}
+		commits = append(commits, row.ForkCommit)
+		if row.SuggestedAction == ActionSquash && len(row.RelatedCommit) > 0 {
+			squashInto[row.ForkCommit] = row.RelatedCommit
+		}
+	}
+
+	fmt.Fprintf(streams.Out, "Writing %d patches to %q\n", len(commits), dir)
+	return kubefork.WritePatchSeries(repoPath, dir, commits, squashInto)
+}
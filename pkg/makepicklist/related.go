@@ -0,0 +1,104 @@
+package makepicklist
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+var (
+	revertsCommitRegex  = regexp.MustCompile(`This reverts commit ([0-9a-f]{7,40})`)
+	revertSubjectRegex  = regexp.MustCompile(`^Revert "(.*)"$`)
+	fixupSubjectRegex   = regexp.MustCompile(`^(?:fixup|squash)! (.*)$`)
+	upstreamPrefixRegex = regexp.MustCompile(`^UPSTREAM: [^:]+: `)
+)
+
+// stripUpstreamPrefix removes the "UPSTREAM: <carry>: " prefix so that a fixup or revert can be matched whether or not
+// its author remembered the prefix.
+func stripUpstreamPrefix(subject string) string {
+	return upstreamPrefixRegex.ReplaceAllString(subject, "")
+}
+
+// findRelated marks revert pairs for dropping and fixup chains for squashing.  Fixups are moved directly after the
+// commit they are squashed into, keeping rev-list order otherwise.
+func findRelated(rows []PickListRow) []PickListRow {
+	for i := range rows {
+		rows[i].SuggestedAction = ActionPick
+	}
+
+	// the most recent earlier row is the one a revert or fixup refers to
+	findEarlier := func(before int, matches func(PickListRow) bool) int {
+		for j := before - 1; j >= 0; j-- {
+			if matches(rows[j]) {
+				return j
+			}
+		}
+		return -1
+	}
+	bySubject := func(subject string) func(PickListRow) bool {
+		normalized := kubefork.NormalizeSubject(stripUpstreamPrefix(subject))
+		return func(row PickListRow) bool {
+			return len(row.RelatedCommit) == 0 && kubefork.NormalizeSubject(stripUpstreamPrefix(row.Description)) == normalized
+		}
+	}
+
+	squashInto := map[int]int{}
+	for i := range rows {
+		subject := stripUpstreamPrefix(rows[i].Description)
+
+		target := -1
+		if matches := revertsCommitRegex.FindStringSubmatch(rows[i].message); matches != nil {
+			target = findEarlier(i, func(row PickListRow) bool {
+				return len(row.RelatedCommit) == 0 && strings.HasPrefix(row.ForkCommit, matches[1])
+			})
+		}
+		if matches := revertSubjectRegex.FindStringSubmatch(subject); target < 0 && matches != nil {
+			target = findEarlier(i, bySubject(matches[1]))
+		}
+		if target >= 0 {
+			rows[i].SuggestedAction, rows[target].SuggestedAction = ActionDrop, ActionDrop
+			rows[i].RelatedCommit, rows[target].RelatedCommit = rows[target].ForkCommit, rows[i].ForkCommit
+			continue
+		}
+
+		if matches := fixupSubjectRegex.FindStringSubmatch(subject); matches != nil {
+			// fixup! fixup! subject squashes into the same commit as fixup! subject
+			targetSubject := matches[1]
+			for nested := fixupSubjectRegex.FindStringSubmatch(targetSubject); nested != nil; nested = fixupSubjectRegex.FindStringSubmatch(targetSubject) {
+				targetSubject = nested[1]
+			}
+			target = findEarlier(i, func(row PickListRow) bool {
+				return row.SuggestedAction == ActionPick && bySubject(targetSubject)(row)
+			})
+			if target >= 0 {
+				rows[i].SuggestedAction = ActionSquash
+				rows[i].RelatedCommit = rows[target].ForkCommit
+				squashInto[i] = target
+			}
+		}
+	}
+
+	// group fixups under their target, in the order the fixups were made
+	fixups := map[int][]int{}
+	for i := range rows {
+		if target, ok := squashInto[i]; ok {
+			fixups[target] = append(fixups[target], i)
+			// squashing into a reverted commit drops the fixup too
+			if rows[target].SuggestedAction == ActionDrop {
+				rows[i].SuggestedAction = ActionDrop
+			}
+		}
+	}
+	ret := make([]PickListRow, 0, len(rows))
+	for i := range rows {
+		if _, ok := squashInto[i]; ok {
+			continue
+		}
+		ret = append(ret, rows[i])
+		for _, fixup := range fixups[i] {
+			ret = append(ret, rows[fixup])
+		}
+	}
+	return ret
+}
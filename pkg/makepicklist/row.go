@@ -0,0 +1,251 @@
+package makepicklist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Columns written by make-pick-list.  Reviewers record their decision in the suggested-action column, and can add
+// upstream-pr and rationale.
+const (
+	ColumnDescription             = "description"
+	ColumnForkCommit              = "fork-commit"
+	ColumnUpstreamCommit          = "upstream-commit"
+	ColumnUpstreamOnReleaseCommit = "upstream-on-release-commit"
+	ColumnSuggestedAction         = "suggested-action"
+	ColumnRelatedCommit           = "related-commit"
+	ColumnUpstreamPR              = "upstream-pr"
+	ColumnRationale               = "rationale"
+
+	// written with --trial-apply
+	ColumnConflictStatus   = "conflict-status"
+	ColumnConflictingFiles = "conflicting-files"
+
+	// written with --metadata
+	ColumnAuthor        = "author"
+	ColumnAuthorDate    = "author-date"
+	ColumnCommitterDate = "committer-date"
+	ColumnFilesTouched  = "files-touched"
+	ColumnLinesAdded    = "lines-added"
+	ColumnLinesRemoved  = "lines-removed"
+	ColumnDirectories   = "directories"
+	ColumnStagingRepos  = "staging-repos"
+
+	// written with --owners or --team-mapping
+	ColumnOwner = "owner"
+
+	// written with --dependencies or --dot-file
+	ColumnGroup     = "group"
+	ColumnDependsOn = "depends-on"
+)
+
+// Suggested actions for a row.
+const (
+	ActionPick   = "pick"
+	ActionDrop   = "drop"
+	ActionSquash = "squash"
+)
+
+// decisionSpellings are the ways reviewers write each action in a spreadsheet.
+var decisionSpellings = map[string]string{
+	"pick":   ActionPick,
+	"keep":   ActionPick,
+	"carry":  ActionPick,
+	"yes":    ActionPick,
+	"y":      ActionPick,
+	"drop":   ActionDrop,
+	"remove": ActionDrop,
+	"delete": ActionDrop,
+	"skip":   ActionDrop,
+	"no":     ActionDrop,
+	"n":      ActionDrop,
+	"squash": ActionSquash,
+	"fixup":  ActionSquash,
+	"s":      ActionSquash,
+}
+
+// NormalizeDecision turns a reviewer's decision into pick, drop, or squash.
+func NormalizeDecision(decision string) (string, error) {
+	normalized, ok := decisionSpellings[strings.ToLower(strings.TrimSpace(decision))]
+	if !ok {
+		return "", fmt.Errorf("decision must be %v, %v, or %v, not %q", ActionPick, ActionDrop, ActionSquash, decision)
+	}
+	return normalized, nil
+}
+
+// PickListHeader is the header row of a pick list, before any optional columns.
+var PickListHeader = []string{
+	ColumnDescription,
+	ColumnForkCommit,
+	ColumnUpstreamCommit,
+	ColumnUpstreamOnReleaseCommit,
+	ColumnSuggestedAction,
+	ColumnRelatedCommit,
+	ColumnUpstreamPR,
+	ColumnRationale,
+}
+
+// PickListRow is a single carry in a pick list.
+type PickListRow struct {
+	Description             string
+	ForkCommit              string
+	UpstreamCommit          string
+	UpstreamOnReleaseCommit string
+	SuggestedAction         string
+	// RelatedCommit is the commit this row reverts, is reverted by, or is squashed into.
+	RelatedCommit string
+	UpstreamPR    string
+	Rationale     string
+
+	// ConflictStatus is the result of trial applying the carry onto the new upstream tag.
+	ConflictStatus   string
+	ConflictingFiles []string
+
+	Author        string
+	AuthorDate    string
+	CommitterDate string
+	FilesTouched  int
+	LinesAdded    int
+	LinesRemoved  int
+	// Directories are the top-level directories touched, like pkg/kubelet or staging/src/k8s.io/apiserver.
+	Directories  []string
+	StagingRepos []string
+
+	// Owner is the teams owning the files touched, or the nearest OWNERS approvers.
+	Owner []string
+
+	// Group numbers the carries connected by dependencies.
+	Group     int
+	DependsOn []string
+
+	// Extra holds columns added by reviewers, by header.
+	Extra map[string]string
+
+	// message is the full commit message, used to find related commits
+	message string
+}
+
+// Value returns the value of a column, or empty for columns the row doesn't know.
+func (r PickListRow) Value(column string) string {
+	switch column {
+	case ColumnDescription:
+		return r.Description
+	case ColumnForkCommit:
+		return r.ForkCommit
+	case ColumnUpstreamCommit:
+		return r.UpstreamCommit
+	case ColumnUpstreamOnReleaseCommit:
+		return r.UpstreamOnReleaseCommit
+	case ColumnSuggestedAction:
+		return r.SuggestedAction
+	case ColumnRelatedCommit:
+		return r.RelatedCommit
+	case ColumnUpstreamPR:
+		return r.UpstreamPR
+	case ColumnRationale:
+		return r.Rationale
+	case ColumnConflictStatus:
+		return r.ConflictStatus
+	case ColumnConflictingFiles:
+		return strings.Join(r.ConflictingFiles, " ")
+	case ColumnAuthor:
+		return r.Author
+	case ColumnAuthorDate:
+		return r.AuthorDate
+	case ColumnCommitterDate:
+		return r.CommitterDate
+	case ColumnFilesTouched:
+		return strconv.Itoa(r.FilesTouched)
+	case ColumnLinesAdded:
+		return strconv.Itoa(r.LinesAdded)
+	case ColumnLinesRemoved:
+		return strconv.Itoa(r.LinesRemoved)
+	case ColumnDirectories:
+		return strings.Join(r.Directories, " ")
+	case ColumnStagingRepos:
+		return strings.Join(r.StagingRepos, " ")
+	case ColumnOwner:
+		return strings.Join(r.Owner, " ")
+	case ColumnGroup:
+		return strconv.Itoa(r.Group)
+	case ColumnDependsOn:
+		return strings.Join(r.DependsOn, " ")
+	}
+	return r.Extra[column]
+}
+
+// SetValue sets a column from its csv value.  Columns the row doesn't know are kept in Extra.
+func (r *PickListRow) SetValue(column, value string) error {
+	var err error
+	switch column {
+	case ColumnDescription:
+		r.Description = value
+	case ColumnForkCommit:
+		r.ForkCommit = value
+	case ColumnUpstreamCommit:
+		r.UpstreamCommit = value
+	case ColumnUpstreamOnReleaseCommit:
+		r.UpstreamOnReleaseCommit = value
+	case ColumnSuggestedAction:
+		r.SuggestedAction = value
+	case ColumnRelatedCommit:
+		r.RelatedCommit = value
+	case ColumnUpstreamPR:
+		r.UpstreamPR = value
+	case ColumnRationale:
+		r.Rationale = value
+	case ColumnConflictStatus:
+		r.ConflictStatus = value
+	case ColumnConflictingFiles:
+		r.ConflictingFiles = strings.Fields(value)
+	case ColumnAuthor:
+		r.Author = value
+	case ColumnAuthorDate:
+		r.AuthorDate = value
+	case ColumnCommitterDate:
+		r.CommitterDate = value
+	case ColumnFilesTouched:
+		r.FilesTouched, err = atoi(value)
+	case ColumnLinesAdded:
+		r.LinesAdded, err = atoi(value)
+	case ColumnLinesRemoved:
+		r.LinesRemoved, err = atoi(value)
+	case ColumnDirectories:
+		r.Directories = strings.Fields(value)
+	case ColumnStagingRepos:
+		r.StagingRepos = strings.Fields(value)
+	case ColumnOwner:
+		r.Owner = strings.Fields(value)
+	case ColumnGroup:
+		r.Group, err = atoi(value)
+	case ColumnDependsOn:
+		r.DependsOn = strings.Fields(value)
+	default:
+		if r.Extra == nil {
+			r.Extra = map[string]string{}
+		}
+		r.Extra[column] = value
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", column, err)
+	}
+	return nil
+}
+
+// atoi allows empty values, which spreadsheets leave for zero.
+func atoi(value string) (int, error) {
+	if len(strings.TrimSpace(value)) == 0 {
+		return 0, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(value))
+}
+
+// Record returns the csv record for the row.
+func (r PickListRow) Record(columns []string) []string {
+	ret := []string{}
+	for _, column := range columns {
+		ret = append(ret, r.Value(column))
+	}
+	return ret
+}
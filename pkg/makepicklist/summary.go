@@ -0,0 +1,39 @@
+package makepicklist
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// writeTeamSummary prints how many carries each owner has to review.  Carries owned by several teams count for each.
+func writeTeamSummary(out io.Writer, rows []PickListRow) error {
+	total := map[string]int{}
+	picks := map[string]int{}
+	for _, row := range rows {
+		owners := row.Owner
+		if len(owners) == 0 {
+			owners = []string{"<none>"}
+		}
+		for _, owner := range owners {
+			total[owner]++
+			if row.SuggestedAction == ActionPick {
+				picks[owner]++
+			}
+		}
+	}
+	owners := []string{}
+	for owner := range total {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	fmt.Fprintf(out, "Carries to review by owner:\n")
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "OWNER\tCARRIES\tSUGGESTED PICKS")
+	for _, owner := range owners {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", owner, total[owner], picks[owner])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,98 @@
+package makepicklist
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// Conflict statuses from --trial-apply.
+const (
+	ConflictStatusClean    = "clean"
+	ConflictStatusConflict = "conflict"
+	// ConflictStatusConflictAfterFailedPick is a conflict only in files touched by earlier carries that failed to pick,
+	// so it is likely to go away once those are resolved.
+	ConflictStatusConflictAfterFailedPick = "conflict-after-failed-pick"
+	// ConflictStatusNotApplicable is for carries git refused to pick before trying, like merges.
+	ConflictStatusNotApplicable = "not-applicable"
+	// ConflictStatusSkipped is for carries suggested for dropping.
+	ConflictStatusSkipped = "skipped"
+)
+
+// trialApply cherry-picks each row, in order, onto startingTag in a temporary worktree and records how it went.
+// Carries that fail to pick are left out, so later carries are picked without them.
+func trialApply(streams genericclioptions.IOStreams, repoPath, startingTag string, rows []PickListRow) error {
+	worktree, err := ioutil.TempDir("", "trial-apply-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(worktree)
+
+	fmt.Fprintf(streams.Out, "Trial applying %d carries onto %q\n", len(rows), startingTag)
+	if err := kubefork.RunCmd(streams.Indent(), repoPath, "git", "worktree", "add", "--detach", worktree, startingTag); err != nil {
+		return err
+	}
+	defer kubefork.RunCmd(streams.Indent(), repoPath, "git", "worktree", "remove", "--force", worktree)
+
+	failedFiles := map[string]bool{}
+	counts := map[string]int{}
+	for i := range rows {
+		row := &rows[i]
+		if row.SuggestedAction == ActionDrop {
+			row.ConflictStatus = ConflictStatusSkipped
+			counts[row.ConflictStatus]++
+			continue
+		}
+
+		// carries that are already upstream become empty.  They are clean, not conflicts.
+		if _, err := kubefork.CollectCmdStdout(worktree, "git", "cherry-pick", "--allow-empty", "--keep-redundant-commits", row.ForkCommit); err == nil {
+			row.ConflictStatus = ConflictStatusClean
+			counts[row.ConflictStatus]++
+			continue
+		}
+
+		// without CHERRY_PICK_HEAD the pick never started, so there is nothing to abort
+		if _, err := kubefork.CollectCmdStdout(worktree, "git", "rev-parse", "--verify", "--quiet", "CHERRY_PICK_HEAD"); err != nil {
+			if _, err := kubefork.CollectCmdStdout(worktree, "git", "reset", "--hard", "--quiet"); err != nil {
+				return err
+			}
+			row.ConflictStatus = ConflictStatusNotApplicable
+		} else {
+			conflicts, err := kubefork.CollectCmdStdout(worktree, "git", "diff", "--name-only", "--diff-filter=U")
+			if err != nil {
+				return err
+			}
+			if _, err := kubefork.CollectCmdStdout(worktree, "git", "cherry-pick", "--abort"); err != nil {
+				return err
+			}
+			row.ConflictingFiles = strings.Fields(conflicts)
+			row.ConflictStatus = ConflictStatusConflictAfterFailedPick
+			for _, file := range row.ConflictingFiles {
+				if !failedFiles[file] {
+					row.ConflictStatus = ConflictStatusConflict
+				}
+			}
+			if len(row.ConflictingFiles) == 0 {
+				row.ConflictStatus = ConflictStatusConflict
+			}
+		}
+		counts[row.ConflictStatus]++
+
+		touched, err := kubefork.CollectCmdStdout(repoPath, "git", "show", "--format=", "--name-only", row.ForkCommit)
+		if err != nil {
+			return err
+		}
+		for _, file := range strings.Fields(touched) {
+			failedFiles[file] = true
+		}
+	}
+
+	fmt.Fprintf(streams.Indent().Out, "%d clean, %d conflict, %d conflict after failed pick, %d not applicable, %d skipped\n",
+		counts[ConflictStatusClean], counts[ConflictStatusConflict], counts[ConflictStatusConflictAfterFailedPick],
+		counts[ConflictStatusNotApplicable], counts[ConflictStatusSkipped])
+	return nil
+}
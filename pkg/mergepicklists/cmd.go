@@ -0,0 +1,296 @@
+package mergepicklists
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/makepicklist"
+	"github.com/spf13/cobra"
+)
+
+type MergePickListsOptions struct {
+	Streams genericclioptions.IOStreams
+
+	Original      string
+	Edited        []string
+	OutFile       string
+	ConflictsFile string
+}
+
+func NewMergePickListsOptions(streams genericclioptions.IOStreams) *MergePickListsOptions {
+	return &MergePickListsOptions{
+		Streams: streams,
+	}
+}
+
+// NewCmdMergePickLists merges the reviews of several copies of a pick list.
+func NewCmdMergePickLists(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewMergePickListsOptions(streams)
+	cmd := &cobra.Command{
+		Use: "merge-pick-lists --original=generated.csv --out-file=merged.csv team-a.csv team-b.csv...",
+		Long: `
+Each edited copy of a pick list is compared with the --original generated by make-pick-list, row by row by
+fork-commit.  The review columns, suggested-action, upstream-pr, owner, rationale, and any columns added by reviewers,
+are merged into --out-file.  A value changed in only one copy, or changed the same way in several, is taken.  When
+copies disagree, the original value is kept and the disagreement is reported, and written to --conflicts-file if set.
+Values are compared ignoring whitespace, and decisions after normalizing their spelling.  The reviewer's value is
+written as is, except for decisions, which are spelled pick, drop, or squash.  Abbreviated fork-commits, which
+spreadsheets are prone to making, are expanded to the commit of the original they are a unique prefix of.  A copy that
+lists a commit more than once is an error.  Rows deleted from a copy count as unchanged.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			o.Edited = args
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.Original, "original", o.Original, "pick list csv generated by make-pick-list")
+	cmd.Flags().StringVar(&o.OutFile, "out-file", o.OutFile, "csv file to write the merged pick list to")
+	cmd.Flags().StringVar(&o.ConflictsFile, "conflicts-file", o.ConflictsFile, "csv file to write the conflicts to")
+
+	return cmd
+}
+
+// reviewColumns are merged from the edited copies.  Columns added by reviewers are merged too.
+var reviewColumns = map[string]bool{
+	makepicklist.ColumnSuggestedAction: true,
+	makepicklist.ColumnUpstreamPR:      true,
+	makepicklist.ColumnOwner:           true,
+	makepicklist.ColumnRationale:       true,
+}
+
+type editedPickList struct {
+	name         string
+	rowsByCommit map[string]makepicklist.PickListRow
+}
+
+type conflict struct {
+	forkCommit  string
+	description string
+	column      string
+	original    string
+	// values has the value of each edited copy, in order
+	values []string
+}
+
+func (o *MergePickListsOptions) Run() error {
+	if len(o.Original) == 0 {
+		return fmt.Errorf("must have original")
+	}
+	if len(o.Edited) == 0 {
+		return fmt.Errorf("must have edited pick lists")
+	}
+	if len(o.OutFile) == 0 {
+		return fmt.Errorf("must have out-file")
+	}
+
+	header, original, err := makepicklist.ReadPickList(o.Original)
+	if err != nil {
+		return err
+	}
+	isOriginalColumn := map[string]bool{}
+	for _, column := range header {
+		isOriginalColumn[column] = true
+	}
+
+	edited := []editedPickList{}
+	for _, filename := range o.Edited {
+		editedHeader, rows, err := makepicklist.ReadPickList(filename)
+		if err != nil {
+			return err
+		}
+		// columns added by reviewers are kept
+		for _, column := range editedHeader {
+			if !isOriginalColumn[column] {
+				isOriginalColumn[column] = true
+				header = append(header, column)
+			}
+		}
+		curr := editedPickList{name: filename, rowsByCommit: map[string]makepicklist.PickListRow{}}
+		// the header is line 1, so rows start on line 2
+		lines := map[string]int{}
+		for i, row := range rows {
+			commit, ok := resolveCommit(original, row.ForkCommit)
+			if !ok {
+				fmt.Fprintf(o.Streams.ErrOut, "WARNING: %s: %q is not in %q, skipping it\n", curr.name, row.ForkCommit, o.Original)
+				continue
+			}
+			if previous, ok := lines[commit]; ok {
+				return fmt.Errorf("%s: %s is listed on line %d and again on line %d", curr.name, commit, previous, i+2)
+			}
+			lines[commit] = i + 2
+			curr.rowsByCommit[commit] = row
+		}
+		edited = append(edited, curr)
+	}
+
+	conflicts := []conflict{}
+	for i := range original {
+		row := &original[i]
+		for _, column := range header {
+			if !reviewColumns[column] && !isAddedColumn(column) {
+				continue
+			}
+			value, rowConflict := mergeValue(*row, column, edited)
+			if rowConflict != nil {
+				conflicts = append(conflicts, *rowConflict)
+				continue
+			}
+			if err := row.SetValue(column, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := makepicklist.WritePickList(o.OutFile, header, original); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Streams.Out, "Merged %d pick lists into %q\n", len(edited), o.OutFile)
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if err := o.writeConflicts(conflicts, edited); err != nil {
+		return err
+	}
+	return fmt.Errorf("%d conflicts kept their original value", len(conflicts))
+}
+
+// isAddedColumn is true for columns make-pick-list never writes.
+func isAddedColumn(column string) bool {
+	for _, known := range (&makepicklist.MakePickListOptions{TrialApply: true, Metadata: true, Owners: true, Dependencies: true}).Columns() {
+		if column == known {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveCommit finds the fork-commit of the original that commit is, or is a unique abbreviation of.
+func resolveCommit(original []makepicklist.PickListRow, commit string) (string, bool) {
+	commit = strings.ToLower(strings.TrimSpace(commit))
+	if len(commit) == 0 {
+		return "", false
+	}
+	found := []string{}
+	for _, row := range original {
+		if row.ForkCommit == commit {
+			return commit, true
+		}
+		if strings.HasPrefix(row.ForkCommit, commit) {
+			found = append(found, row.ForkCommit)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// mergeValue does a three-way merge of one cell.  A changed value is taken as the reviewer wrote it, except that
+// decisions are normalized.
+func mergeValue(row makepicklist.PickListRow, column string, edited []editedPickList) (string, *conflict) {
+	original := row.Value(column)
+	// changed is the first spelling of every changed value by its comparable form
+	changed := map[string]string{}
+	values := []string{}
+	for _, curr := range edited {
+		editedRow, ok := curr.rowsByCommit[row.ForkCommit]
+		if !ok {
+			values = append(values, original)
+			continue
+		}
+		value := editedRow.Value(column)
+		values = append(values, value)
+		if sameValue(column, original, value) {
+			continue
+		}
+		if _, ok := changed[comparable(column, value)]; !ok {
+			changed[comparable(column, value)] = value
+		}
+	}
+
+	switch len(changed) {
+	case 0:
+		return original, nil
+	case 1:
+		for normalized, value := range changed {
+			if column == makepicklist.ColumnSuggestedAction {
+				return normalized, nil
+			}
+			return value, nil
+		}
+	}
+	return original, &conflict{
+		forkCommit:  row.ForkCommit,
+		description: row.Description,
+		column:      column,
+		original:    original,
+		values:      values,
+	}
+}
+
+func sameValue(column, a, b string) bool {
+	return comparable(column, a) == comparable(column, b)
+}
+
+// comparable ignores differences spreadsheets and reviewers make that don't change meaning.
+func comparable(column, value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if column == makepicklist.ColumnSuggestedAction {
+		if decision, err := makepicklist.NormalizeDecision(value); err == nil {
+			return decision
+		}
+	}
+	return value
+}
+
+func (o *MergePickListsOptions) writeConflicts(conflicts []conflict, edited []editedPickList) error {
+	header := []string{makepicklist.ColumnForkCommit, makepicklist.ColumnDescription, "column", "original"}
+	for _, curr := range edited {
+		header = append(header, curr.name)
+	}
+
+	fmt.Fprintf(o.Streams.Out, "\nConflicts:\n")
+	w := tabwriter.NewWriter(o.Streams.Out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "FORK-COMMIT\tDESCRIPTION\tCOLUMN\tORIGINAL\t%s\n", strings.Join(header[4:], "\t"))
+	for _, curr := range conflicts {
+		fmt.Fprintln(w, strings.Join(curr.record(), "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(o.ConflictsFile) == 0 {
+		return nil
+	}
+	outfile, err := os.Create(o.ConflictsFile)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+	csvWriter := csv.NewWriter(outfile)
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+	for _, curr := range conflicts {
+		if err := csvWriter.Write(curr.record()); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		return err
+	}
+	return outfile.Close()
+}
+
+func (c conflict) record() []string {
+	return append([]string{c.forkCommit, c.description, c.column, c.original}, c.values...)
+}
@@ -0,0 +1,174 @@
+package mergepicklists
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/makepicklist"
+)
+
+const (
+	commitA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	commitB = "aaaaaaabbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	commitC = "cccccccccccccccccccccccccccccccccccccccc"
+)
+
+func TestComparable(t *testing.T) {
+	tests := []struct {
+		column   string
+		value    string
+		expected string
+	}{
+		{column: makepicklist.ColumnSuggestedAction, value: "pick", expected: "pick"},
+		{column: makepicklist.ColumnSuggestedAction, value: " Keep ", expected: "pick"},
+		{column: makepicklist.ColumnSuggestedAction, value: "N", expected: "drop"},
+		{column: makepicklist.ColumnSuggestedAction, value: "fixup", expected: "squash"},
+		{column: makepicklist.ColumnSuggestedAction, value: "maybe  later", expected: "maybe later"},
+		{column: makepicklist.ColumnRationale, value: "  fixed\n upstream ", expected: "fixed upstream"},
+		// only decisions have spellings
+		{column: makepicklist.ColumnRationale, value: "Keep", expected: "Keep"},
+		{column: "team-notes", value: "", expected: ""},
+	}
+	for _, test := range tests {
+		if actual := comparable(test.column, test.value); actual != test.expected {
+			t.Errorf("%v %q: expected %q, got %q", test.column, test.value, test.expected, actual)
+		}
+	}
+}
+
+func TestResolveCommit(t *testing.T) {
+	original := []makepicklist.PickListRow{{ForkCommit: commitA}, {ForkCommit: commitB}, {ForkCommit: commitC}}
+	tests := []struct {
+		name     string
+		commit   string
+		expected string
+		ok       bool
+	}{
+		{name: "full", commit: commitC, expected: commitC, ok: true},
+		{name: "abbreviated", commit: "ccccccc", expected: commitC, ok: true},
+		{name: "spreadsheet spelling", commit: " CCCCCCC ", expected: commitC, ok: true},
+		{name: "full that prefixes nothing else", commit: commitA, expected: commitA, ok: true},
+		{name: "longer than the ambiguous part", commit: "aaaaaaab", expected: commitB, ok: true},
+		{name: "ambiguous", commit: "aaaaaaa", ok: false},
+		{name: "unknown", commit: "ddddddd", ok: false},
+		{name: "empty", commit: "", ok: false},
+	}
+	for _, test := range tests {
+		actual, ok := resolveCommit(original, test.commit)
+		if actual != test.expected || ok != test.ok {
+			t.Errorf("%s: expected %q, %v, got %q, %v", test.name, test.expected, test.ok, actual, ok)
+		}
+	}
+}
+
+func TestMergeValue(t *testing.T) {
+	original := makepicklist.PickListRow{
+		Description:     "UPSTREAM: <carry>: a",
+		ForkCommit:      commitA,
+		SuggestedAction: makepicklist.ActionPick,
+		Rationale:       "needed by the installer",
+	}
+	// edited returns a copy of the pick list with the row changed, or without the row if change is nil
+	edited := func(name string, change func(*makepicklist.PickListRow)) editedPickList {
+		ret := editedPickList{name: name, rowsByCommit: map[string]makepicklist.PickListRow{}}
+		if change != nil {
+			row := original
+			change(&row)
+			ret.rowsByCommit[row.ForkCommit] = row
+		}
+		return ret
+	}
+	unchanged := func(*makepicklist.PickListRow) {}
+	decision := func(value string) func(*makepicklist.PickListRow) {
+		return func(row *makepicklist.PickListRow) { row.SuggestedAction = value }
+	}
+	rationale := func(value string) func(*makepicklist.PickListRow) {
+		return func(row *makepicklist.PickListRow) { row.Rationale = value }
+	}
+
+	tests := []struct {
+		name     string
+		column   string
+		edited   []editedPickList
+		expected string
+		// conflictValues are the values of a conflict, nil if there should be none
+		conflictValues []string
+	}{
+		{
+			name:     "unchanged",
+			column:   makepicklist.ColumnSuggestedAction,
+			edited:   []editedPickList{edited("a", unchanged), edited("b", unchanged)},
+			expected: makepicklist.ActionPick,
+		},
+		{
+			name:     "respelled but unchanged",
+			column:   makepicklist.ColumnSuggestedAction,
+			edited:   []editedPickList{edited("a", decision("Keep")), edited("b", unchanged)},
+			expected: makepicklist.ActionPick,
+		},
+		{
+			name:     "changed in one copy",
+			column:   makepicklist.ColumnSuggestedAction,
+			edited:   []editedPickList{edited("a", unchanged), edited("b", decision("drop"))},
+			expected: makepicklist.ActionDrop,
+		},
+		{
+			name:     "changed the same way with different spellings",
+			column:   makepicklist.ColumnSuggestedAction,
+			edited:   []editedPickList{edited("a", decision("No")), edited("b", decision("remove"))},
+			expected: makepicklist.ActionDrop,
+		},
+		{
+			name:     "deleted rows count as unchanged",
+			column:   makepicklist.ColumnSuggestedAction,
+			edited:   []editedPickList{edited("a", nil), edited("b", decision("squash"))},
+			expected: makepicklist.ActionSquash,
+		},
+		{
+			name:           "changed differently",
+			column:         makepicklist.ColumnSuggestedAction,
+			edited:         []editedPickList{edited("a", decision("drop")), edited("b", decision("squash")), edited("c", nil)},
+			expected:       makepicklist.ActionPick,
+			conflictValues: []string{"drop", "squash", makepicklist.ActionPick},
+		},
+		{
+			name:     "the reviewer's spelling is written",
+			column:   makepicklist.ColumnRationale,
+			edited:   []editedPickList{edited("a", rationale("Fixed  upstream in\n1.16")), edited("b", unchanged)},
+			expected: "Fixed  upstream in\n1.16",
+		},
+		{
+			name:     "whitespace only changes are unchanged",
+			column:   makepicklist.ColumnRationale,
+			edited:   []editedPickList{edited("a", rationale(" needed by  the installer "))},
+			expected: "needed by the installer",
+		},
+		{
+			name:     "changed the same way with different whitespace takes the first copy",
+			column:   makepicklist.ColumnRationale,
+			edited:   []editedPickList{edited("a", rationale("fixed upstream ")), edited("b", rationale("fixed  upstream"))},
+			expected: "fixed upstream ",
+		},
+		{
+			name:           "rationales that differ",
+			column:         makepicklist.ColumnRationale,
+			edited:         []editedPickList{edited("a", rationale("fixed upstream")), edited("b", rationale("still needed"))},
+			expected:       "needed by the installer",
+			conflictValues: []string{"fixed upstream", "still needed"},
+		},
+	}
+	for _, test := range tests {
+		actual, conflict := mergeValue(original, test.column, test.edited)
+		if actual != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, actual)
+		}
+		switch {
+		case conflict == nil && test.conflictValues != nil:
+			t.Errorf("%s: expected a conflict", test.name)
+		case conflict != nil && test.conflictValues == nil:
+			t.Errorf("%s: unexpected conflict %v", test.name, conflict.values)
+		case conflict != nil && !reflect.DeepEqual(conflict.values, test.conflictValues):
+			t.Errorf("%s: expected conflicting values %q, got %q", test.name, test.conflictValues, conflict.values)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package publishforkbranch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+)
+
+type PublishForkBranchOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	ForkOwner   string // like origin
+	ForkVersion string // like 4.2
+	KubeVersion string // like 1.15.0
+
+	Repo   string // optional, only publish this staging repo
+	DryRun bool
+}
+
+func NewPublishForkBranchOptions(streams genericclioptions.IOStreams) *PublishForkBranchOptions {
+	return &PublishForkBranchOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdPublishForkBranch publishes staging carries from openshift/kubernetes to the openshift/kubernetes-<repo> forks.
+func NewCmdPublishForkBranch(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewPublishForkBranchOptions(streams)
+	cmd := &cobra.Command{
+		Use: "publish-fork-branch --kube-home=/path/to/k8s.io --fork-owner=origin --fork-version=4.3 --kube-version=1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+For every staging repo, the commits on the openshift/kubernetes fork branch that touch staging/src/k8s.io/<repo> and
+are not yet published are filtered down to that directory and appended to the same fork branch in
+openshift/kubernetes-<repo> with a Kubernetes-commit trailer, the same way the upstream publishing bot does.  The last
+Kubernetes-commit trailer on the first-parent history of the staging fork branch is where publishing resumes.
+
+When the kubernetes fork branch merged an upstream release, like rebase-fork-branch --strategy=merge does, the
+matching kubernetes-<version> tag is merged into the staging fork branch and the result takes the staging directory of
+the kubernetes merge, so that conflict resolutions are published too.
+
+Publishing then rewrites go.mod (and Godeps.json for older versions) in dependency order so that every require and
+replace of a sibling staging repo points at the pseudo-version of the sibling's fork branch commit from the same run.
+The rest of the manifests are copied from the kubernetes fork branch.  go.sum gets the hashes of the pinned siblings,
+computed from the local clones the way the go command does after downloading them, in place of their upstream lines.
+
+If any repo fails to publish, nothing is pushed.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.14.1")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "only publish this staging repo, like apimachinery, client-go")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "publish into the local clones, but don't push")
+
+	return cmd
+}
+
+func (o *PublishForkBranchOptions) Run() error {
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	repoInfos, err = kubefork.ReposForKubeVersion(repoInfos, o.KubeVersion)
+	if err != nil {
+		return err
+	}
+	kubeInfo := repoInfos[0]
+
+	// every staging repo is fetched because siblings are pinned even when only one repo is published
+	allStagingInfos := map[string]kubefork.RepoInfo{}
+	stagingNames := []string{}
+	for _, currInfo := range repoInfos[1:] {
+		fmt.Fprintf(o.Streams.Out, "Check kubernetes/%v\n", currInfo.UpstreamName)
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		allStagingInfos[currInfo.UpstreamName] = currInfo
+		stagingNames = append(stagingNames, currInfo.UpstreamName)
+	}
+	if _, ok := allStagingInfos[o.Repo]; len(o.Repo) > 0 && !ok {
+		return fmt.Errorf("%q is not a staging repo in kube %v", o.Repo, o.KubeVersion)
+	}
+	toPublish := func(stagingName string) bool {
+		return len(o.Repo) == 0 || stagingName == o.Repo
+	}
+
+	failed := []string{}
+	for _, stagingName := range stagingNames {
+		if !toPublish(stagingName) {
+			continue
+		}
+		currInfo := allStagingInfos[stagingName]
+		fmt.Fprintf(o.Streams.Out, "Publish kubernetes/%v\n", currInfo.UpstreamName)
+		if err := publishRepo(o.Streams.Indent(), kubeInfo, currInfo, forkBranch); err != nil {
+			fmt.Fprintf(o.Streams.ErrOut, "For kubernetes/%v, failed to publish: %v\n", currInfo.UpstreamName, err)
+			failed = append(failed, currInfo.UpstreamName)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to publish %v, nothing was pushed", failed)
+	}
+
+	// pin siblings in dependency order so that every repo pins the final commit of the repos it depends on
+	kubeRef := kubefork.OpenShiftBranchRef(forkBranch)
+	isStaging := map[string]bool{}
+	for _, stagingName := range stagingNames {
+		isStaging[stagingName] = true
+	}
+	dependencies := map[string][]string{}
+	for _, stagingName := range stagingNames {
+		dependencies[stagingName] = stagingDependencies(kubeInfo.Path, kubeRef, stagingName, isStaging)
+	}
+	ordered, err := orderByDependencies(stagingNames, dependencies)
+	if err != nil {
+		return err
+	}
+	pins := map[string]siblingPin{}
+	for _, stagingName := range ordered {
+		currInfo := allStagingInfos[stagingName]
+		ref := kubeRef
+		if toPublish(stagingName) {
+			if _, err := rewriteManifests(o.Streams.Indent(), kubeInfo, currInfo, kubeRef, pins); err != nil {
+				return fmt.Errorf("failed to pin siblings of %v, nothing was pushed: %v", stagingName, err)
+			}
+			ref = "HEAD"
+		}
+
+		pin, err := newSiblingPin(currInfo, ref)
+		if err != nil {
+			return fmt.Errorf("failed to pin siblings to %v, nothing was pushed: %v", stagingName, err)
+		}
+		pins[stagingName] = pin
+	}
+
+	for _, stagingName := range stagingNames {
+		if !toPublish(stagingName) {
+			continue
+		}
+		currInfo := allStagingInfos[stagingName]
+		pushedSHA, err := kubefork.CollectCmdStdout(currInfo.Path, "git", "rev-parse", kubefork.OpenShiftBranchRef(forkBranch))
+		if err != nil {
+			return err
+		}
+		if pins[stagingName].SHA == strings.TrimSpace(pushedSHA) {
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, %q has nothing new to push\n", currInfo.UpstreamName, forkBranch.BranchName())
+			continue
+		}
+		if o.DryRun {
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, not pushing %q because of --dry-run\n", currInfo.UpstreamName, forkBranch.BranchName())
+			continue
+		}
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, pushing %q to %q\n", currInfo.UpstreamName, forkBranch.BranchName(), currInfo.Openshift.Name)
+		if err := kubefork.RunCmd(o.Streams.Indent(), currInfo.Path, "git", "push", currInfo.Openshift.Name, forkBranch.BranchName()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package publishforkbranch
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// moduleHashes returns the go.sum hashes of the module at commit in the repo, as the go command would compute them
+// after downloading modulePath@version: one for the module zip and one for its go.mod.  goModHash is empty if the
+// module has no go.mod.
+func moduleHashes(repoPath, commit, modulePath, version string) (string, string, error) {
+	// like the go command, so that line endings don't depend on the local git config
+	cmd := exec.Command("git", "-c", "core.autocrlf=input", "-c", "core.eol=lf", "archive", "--format=tar", commit)
+	cmd.Dir = repoPath
+	archive, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", "", err
+	}
+
+	fileHashes := map[string][]byte{}
+	var goMod []byte
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Wait()
+			return "", "", err
+		}
+		// module zips only have regular files
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		content, err := ioutil.ReadAll(reader)
+		if err != nil {
+			cmd.Wait()
+			return "", "", err
+		}
+		if header.Name == "go.mod" {
+			goMod = content
+		}
+		fileHash := sha256.Sum256(content)
+		fileHashes[header.Name] = fileHash[:]
+	}
+	if err := cmd.Wait(); err != nil {
+		return "", "", err
+	}
+
+	// like the go command, leave out nested modules, vendored packages, and hg archive metadata
+	nestedModules := []string{}
+	for name := range fileHashes {
+		dir, base := path.Split(name)
+		if len(dir) > 0 && strings.EqualFold(base, "go.mod") {
+			nestedModules = append(nestedModules, dir)
+		}
+	}
+	fixedVendoring := goVersionAtLeast(goMod, 1, 24)
+	zipHashes := map[string][]byte{}
+	for name, fileHash := range fileHashes {
+		if isVendoredPackage(name, fixedVendoring) || inAnyDirectory(name, nestedModules) || name == ".hg_archival.txt" {
+			continue
+		}
+		zipHashes[modulePath+"@"+version+"/"+name] = fileHash
+	}
+
+	zipHash, err := hash1(zipHashes)
+	if err != nil {
+		return "", "", err
+	}
+	if goMod == nil {
+		return zipHash, "", nil
+	}
+	goModFileHash := sha256.Sum256(goMod)
+	goModHash, err := hash1(map[string][]byte{"go.mod": goModFileHash[:]})
+	if err != nil {
+		return "", "", err
+	}
+	return zipHash, goModHash, nil
+}
+
+// hash1 is the h1: hash of go.sum, a sha256 of the sorted sha256sum lines of the files.
+func hash1(fileHashes map[string][]byte) (string, error) {
+	names := []string{}
+	for name := range fileHashes {
+		if strings.Contains(name, "\n") {
+			return "", fmt.Errorf("file name %q contains a newline", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	summary := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(summary, "%x  %s\n", fileHashes[name], name)
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+var goDirectiveRegex = regexp.MustCompile(`(?m)^go\s+([0-9]+)\.([0-9]+)`)
+
+// goVersionAtLeast is true if the go directive of goMod is at least major.minor.
+func goVersionAtLeast(goMod []byte, major, minor int) bool {
+	matches := goDirectiveRegex.FindSubmatch(goMod)
+	if matches == nil {
+		return false
+	}
+	goMajor, _ := strconv.Atoi(string(matches[1]))
+	goMinor, _ := strconv.Atoi(string(matches[2]))
+	return goMajor > major || (goMajor == major && goMinor >= minor)
+}
+
+// isVendoredPackage is a copy of the go command's, bugs included, because fixing them would change module hashes.
+// Before go 1.24, a vendor directory below the module root only skips len("/vendor/") into the name, so everything
+// below it counts as vendored, and vendor/modules.txt is kept.  fixedVendoring is true for modules that declare go 1.24
+// or later.
+func isVendoredPackage(name string, fixedVendoring bool) bool {
+	if fixedVendoring && name == "vendor/modules.txt" {
+		return true
+	}
+	var i int
+	if strings.HasPrefix(name, "vendor/") {
+		i += len("vendor/")
+	} else if j := strings.Index(name, "/vendor/"); j >= 0 {
+		if fixedVendoring {
+			i = j + len("/vendor/")
+		} else {
+			i += len("/vendor/")
+		}
+	} else {
+		return false
+	}
+	return strings.Contains(name[i:], "/")
+}
+
+func inAnyDirectory(name string, directories []string) bool {
+	for _, directory := range directories {
+		if strings.HasPrefix(name, directory) {
+			return true
+		}
+	}
+	return false
+}
+
+// pinGoSum replaces the go.sum lines of the pinned siblings with the hashes of their fork commits.  Lines for the
+// upstream versions of the siblings are dropped, because the replace directives make them unused.
+func pinGoSum(goSumPath string, pins map[string]siblingPin, pinned []string) error {
+	content, err := ioutil.ReadFile(goSumPath)
+	if err != nil {
+		return err
+	}
+
+	stale := map[string]bool{}
+	for _, sibling := range pinned {
+		stale["k8s.io/"+sibling] = true
+		stale[pins[sibling].ModulePath] = true
+	}
+	lines := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || stale[fields[0]] {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for _, sibling := range pinned {
+		pin := pins[sibling]
+		if len(pin.GoModHash) == 0 {
+			return fmt.Errorf("%v has no go.mod at %v", pin.ModulePath, pin.SHA)
+		}
+		lines = append(lines,
+			fmt.Sprintf("%s %s %s", pin.ModulePath, pin.PseudoVersion, pin.ZipHash),
+			fmt.Sprintf("%s %s/go.mod %s", pin.ModulePath, pin.PseudoVersion, pin.GoModHash),
+		)
+	}
+	// go.sum is sorted by module, and the order within a module is kept
+	sort.SliceStable(lines, func(i, j int) bool {
+		return strings.Fields(lines[i])[0] < strings.Fields(lines[j])[0]
+	})
+
+	return ioutil.WriteFile(goSumPath, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
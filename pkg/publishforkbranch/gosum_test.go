@@ -0,0 +1,146 @@
+package publishforkbranch
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModuleHashes(t *testing.T) {
+	// the go.sum lines of github.com/goware/modvendor v0.5.0 from sum.golang.org.  It declares go 1.14 and has a vendor
+	// directory, so vendor/modules.txt is hashed but the vendored packages are not.
+	const (
+		modulePath        = "github.com/goware/modvendor"
+		version           = "v0.5.0"
+		expectedZipHash   = "h1:3XXkmWdTccMzBswM5FTTXvWEtCV7DP7VRkIACRCGaqU="
+		expectedGoModHash = "h1:rtogeSlPLJT6MlypJyGp24o/vnHvF+ebCoTQrDX6oGY="
+	)
+
+	repoPath, err := ioutil.TempDir("", "gosum-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoPath)
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	git("config", "user.name", "test")
+	git("config", "user.email", "test@example.com")
+	// archives must not depend on the local line ending config
+	git("config", "core.autocrlf", "true")
+
+	files := map[string]string{}
+	moduleDir := filepath.Join("testdata", "modvendor-v0.5.0")
+	err = filepath.Walk(moduleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// files the go command leaves out of the module zip, so they must not change the hashes
+	for _, name := range []string{
+		"vendor/github.com/mattn/go-zglob/zglob.go",
+		"internal/vendor/vendor.go",
+		"internal/vendor/fastwalk/fastwalk.go",
+		"nested/go.mod",
+		"nested/nested.go",
+		"other/GO.MOD",
+		"other/other.go",
+		".hg_archival.txt",
+	} {
+		files[name] = "package left_out\n"
+	}
+	for name, content := range files {
+		path := filepath.Join(repoPath, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("main.go", filepath.Join(repoPath, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "--all")
+	git("commit", "--quiet", "-m", "module")
+
+	zipHash, goModHash, err := moduleHashes(repoPath, git("rev-parse", "HEAD"), modulePath, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zipHash != expectedZipHash {
+		t.Errorf("expected zip hash %v, got %v", expectedZipHash, zipHash)
+	}
+	if goModHash != expectedGoModHash {
+		t.Errorf("expected go.mod hash %v, got %v", expectedGoModHash, goModHash)
+	}
+}
+
+func TestIsVendoredPackage(t *testing.T) {
+	tests := []struct {
+		name           string
+		fixedVendoring bool
+		expected       bool
+	}{
+		{name: "main.go", expected: false},
+		{name: "vendor.go", expected: false},
+		{name: "vendor/modules.txt", expected: false},
+		{name: "vendor/modules.txt", fixedVendoring: true, expected: true},
+		{name: "vendor/vendor.go", expected: false},
+		{name: "vendor/k8s.io/api/types.go", expected: true},
+		{name: "pkg/vendor/vendor.go", expected: true},
+		{name: "pkg/vendor/vendor.go", fixedVendoring: true, expected: false},
+		{name: "pkg/vendor/foo/foo.go", expected: true},
+		{name: "pkg/vendor/foo/foo.go", fixedVendoring: true, expected: true},
+		{name: "pkg/vendored/foo.go", expected: false},
+	}
+	for _, test := range tests {
+		if actual := isVendoredPackage(test.name, test.fixedVendoring); actual != test.expected {
+			t.Errorf("%v with fixedVendoring=%v: expected %v, got %v", test.name, test.fixedVendoring, test.expected, actual)
+		}
+	}
+}
+
+func TestGoVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		goMod    string
+		expected bool
+	}{
+		{goMod: "module k8s.io/api\n", expected: false},
+		{goMod: "module k8s.io/api\n\ngo 1.12\n", expected: false},
+		{goMod: "module k8s.io/api\n\ngo 1.24\n", expected: true},
+		{goMod: "module k8s.io/api\n\ngo 1.24.0\n", expected: true},
+		{goMod: "module k8s.io/api\n\ngo 1.24rc1\n", expected: true},
+		{goMod: "module k8s.io/api\n\ngo 1.100\n", expected: true},
+		{goMod: "module k8s.io/api\n\ngo 2.0\n", expected: true},
+	}
+	for _, test := range tests {
+		if actual := goVersionAtLeast([]byte(test.goMod), 1, 24); actual != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.goMod, test.expected, actual)
+		}
+	}
+}
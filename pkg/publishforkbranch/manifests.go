@@ -0,0 +1,299 @@
+package publishforkbranch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// siblingPin is the published commit of a staging fork branch that its siblings should depend on.
+type siblingPin struct {
+	SHA           string
+	PseudoVersion string
+	// ModulePath is where the fork lives, like github.com/openshift/kubernetes-apimachinery
+	ModulePath string
+	// ZipHash and GoModHash are the go.sum hashes of the module at SHA.
+	ZipHash   string
+	GoModHash string
+}
+
+func newSiblingPin(stagingInfo kubefork.RepoInfo, ref string) (siblingPin, error) {
+	sha, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "rev-parse", ref+"^{commit}")
+	if err != nil {
+		return siblingPin{}, err
+	}
+	sha = strings.TrimSpace(sha)
+	commitTime, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "show", "-s", "--format=%ct", sha)
+	if err != nil {
+		return siblingPin{}, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(commitTime), 10, 64)
+	if err != nil {
+		return siblingPin{}, err
+	}
+
+	pin := siblingPin{
+		SHA:           sha,
+		PseudoVersion: fmt.Sprintf("v0.0.0-%s-%s", time.Unix(seconds, 0).UTC().Format("20060102150405"), sha[:12]),
+		ModulePath:    "github.com/openshift/" + stagingInfo.OpenshiftName,
+	}
+	pin.ZipHash, pin.GoModHash, err = moduleHashes(stagingInfo.Path, sha, pin.ModulePath, pin.PseudoVersion)
+	if err != nil {
+		return siblingPin{}, err
+	}
+	return pin, nil
+}
+
+var stagingImportRegex = regexp.MustCompile(`k8s\.io/([a-z0-9-]+)`)
+
+// stagingDependencies returns the sibling staging repos that a staging repo depends on in kubernetes at ref,
+// according to its go.mod or, for older versions, its Godeps.json.
+func stagingDependencies(kubePath, ref, stagingName string, siblings map[string]bool) []string {
+	content := ""
+	for _, manifest := range []string{"go.mod", "Godeps/Godeps.json"} {
+		var err error
+		content, err = kubefork.CollectCmdStdout(kubePath, "git", "show", ref+":"+kubefork.StagingPath(stagingName)+"/"+manifest)
+		if err == nil {
+			break
+		}
+	}
+
+	found := map[string]bool{}
+	for _, match := range stagingImportRegex.FindAllStringSubmatch(content, -1) {
+		if match[1] != stagingName && siblings[match[1]] {
+			found[match[1]] = true
+		}
+	}
+	ret := []string{}
+	for sibling := range found {
+		ret = append(ret, sibling)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// orderByDependencies sorts staging repos so that every repo comes after the repos it depends on.
+func orderByDependencies(names []string, dependencies map[string][]string) ([]string, error) {
+	sortedNames := append([]string{}, names...)
+	sort.Strings(sortedNames)
+
+	ret := []string{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+		if visiting[name] {
+			return fmt.Errorf("staging repos have a dependency cycle through %q", name)
+		}
+		visiting[name] = true
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		ret = append(ret, name)
+		return nil
+	}
+	for _, name := range sortedNames {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// rewriteManifests makes the manifests of the checked out staging fork branch match kubernetes at kubeRef, with every
+// sibling dependency pinned to the sibling's fork branch and go.sum lines for the pinned siblings.  It commits and
+// returns true if anything changed.
+func rewriteManifests(streams genericclioptions.IOStreams, kubeInfo, stagingInfo kubefork.RepoInfo, kubeRef string, pins map[string]siblingPin) (bool, error) {
+	manifests := []string{}
+	for _, manifest := range kubefork.PublishingManifests {
+		content, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", "show", kubeRef+":"+kubefork.StagingPath(stagingInfo.UpstreamName)+"/"+manifest)
+		if err != nil {
+			// kubernetes doesn't have it, so neither should we have a reason to change it
+			continue
+		}
+		manifestPath := filepath.Join(stagingInfo.Path, manifest)
+		if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+			return false, err
+		}
+		if err := ioutil.WriteFile(manifestPath, []byte(content), 0644); err != nil {
+			return false, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	pinned := []string{}
+	for _, manifest := range manifests {
+		var err error
+		switch manifest {
+		case "go.mod":
+			pinned, err = pinGoMod(streams, stagingInfo.Path, pins)
+		case "Godeps/Godeps.json":
+			err = pinGodeps(filepath.Join(stagingInfo.Path, manifest), pins)
+		}
+		if err != nil {
+			return false, fmt.Errorf("unable to rewrite %v: %v", manifest, err)
+		}
+	}
+	if len(pinned) > 0 {
+		goSumPath := filepath.Join(stagingInfo.Path, "go.sum")
+		if _, err := os.Stat(goSumPath); os.IsNotExist(err) {
+			if err := ioutil.WriteFile(goSumPath, []byte{}, 0644); err != nil {
+				return false, err
+			}
+			manifests = append(manifests, "go.sum")
+		}
+		if err := pinGoSum(goSumPath, pins, pinned); err != nil {
+			return false, fmt.Errorf("unable to rewrite go.sum: %v", err)
+		}
+	}
+	if len(manifests) == 0 {
+		return false, nil
+	}
+
+	status, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", append([]string{"status", "--porcelain", "--"}, manifests...)...)
+	if err != nil {
+		return false, err
+	}
+	if len(strings.TrimSpace(status)) == 0 {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, manifests are up to date\n", stagingInfo.UpstreamName)
+		return false, nil
+	}
+
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, updating %v\n", stagingInfo.UpstreamName, strings.Join(manifests, ", "))
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", append([]string{"add", "--"}, manifests...)...); err != nil {
+		return false, err
+	}
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "commit", "--quiet", "-m", "sync: pin sibling staging repos to their fork branches"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type goModJSON struct {
+	Require []struct {
+		Path string
+	}
+	Replace []struct {
+		Old struct {
+			Path string
+		}
+	}
+}
+
+// pinGoMod points every require and replace of a sibling at the sibling's fork and returns the siblings it pinned.
+// Siblings that aren't mentioned are left alone.
+func pinGoMod(streams genericclioptions.IOStreams, stagingPath string, pins map[string]siblingPin) ([]string, error) {
+	content := &bytes.Buffer{}
+	if err := goModCmd(genericclioptions.IOStreams{Out: content, ErrOut: streams.ErrOut}, stagingPath, "-json").Run(); err != nil {
+		return nil, err
+	}
+	goMod := goModJSON{}
+	if err := json.Unmarshal(content.Bytes(), &goMod); err != nil {
+		return nil, err
+	}
+
+	mentioned := map[string]bool{}
+	for _, require := range goMod.Require {
+		mentioned[require.Path] = true
+	}
+	for _, replace := range goMod.Replace {
+		mentioned[replace.Old.Path] = true
+	}
+
+	args := []string{}
+	pinned := []string{}
+	siblings := []string{}
+	for sibling := range pins {
+		siblings = append(siblings, sibling)
+	}
+	sort.Strings(siblings)
+	for _, sibling := range siblings {
+		modulePath := "k8s.io/" + sibling
+		if !mentioned[modulePath] {
+			continue
+		}
+		pin := pins[sibling]
+		args = append(args,
+			"-require="+modulePath+"@"+pin.PseudoVersion,
+			"-replace="+modulePath+"="+pin.ModulePath+"@"+pin.PseudoVersion,
+		)
+		pinned = append(pinned, sibling)
+	}
+	if len(args) == 0 {
+		return pinned, nil
+	}
+	fmt.Fprintf(streams.Out, "pushd %q && go mod edit %s; popd\n", stagingPath, strings.Join(args, " "))
+	return pinned, goModCmd(streams.Indent(), stagingPath, args...).Run()
+}
+
+// goModCmd runs go mod edit in module mode, which GOPATH or GO111MODULE=off would otherwise prevent.
+func goModCmd(streams genericclioptions.IOStreams, stagingPath string, args ...string) *exec.Cmd {
+	cmd := exec.Command("go", append([]string{"mod", "edit"}, args...)...)
+	cmd.Dir = stagingPath
+	cmd.Env = append(os.Environ(), "GO111MODULE=on")
+	cmd.Stdout = streams.Out
+	cmd.Stderr = streams.ErrOut
+	return cmd
+}
+
+// godeps matches the Godeps.json written by godep.
+type godeps struct {
+	ImportPath   string
+	GoVersion    string
+	GodepVersion string
+	Packages     []string `json:",omitempty"`
+	Deps         []godepsDependency
+}
+
+type godepsDependency struct {
+	ImportPath string
+	Comment    string `json:",omitempty"`
+	Rev        string
+}
+
+// pinGodeps points every package of a sibling at the sibling's fork commit.
+func pinGodeps(godepsPath string, pins map[string]siblingPin) error {
+	content, err := ioutil.ReadFile(godepsPath)
+	if err != nil {
+		return err
+	}
+	deps := godeps{}
+	if err := json.Unmarshal(content, &deps); err != nil {
+		return err
+	}
+
+	for i, dep := range deps.Deps {
+		for sibling, pin := range pins {
+			modulePath := "k8s.io/" + sibling
+			if dep.ImportPath == modulePath || strings.HasPrefix(dep.ImportPath, modulePath+"/") {
+				deps.Deps[i].Rev = pin.SHA
+				deps.Deps[i].Comment = ""
+			}
+		}
+	}
+
+	newContent, err := json.MarshalIndent(deps, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(godepsPath, append(newContent, '\n'), 0644)
+}
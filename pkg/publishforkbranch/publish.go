@@ -0,0 +1,267 @@
+package publishforkbranch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// publishRepo appends the unpublished staging commits of the kubernetes fork branch to the local fork branch of the
+// staging repo, leaving that branch checked out.
+func publishRepo(streams genericclioptions.IOStreams, kubeInfo, stagingInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo) error {
+	branchName := forkBranch.BranchName()
+	branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+
+	kubeRepo, err := git.PlainOpen(kubeInfo.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := kubefork.FindOpenShiftBranch(branchName, kubeRepo); err != nil {
+		return fmt.Errorf("kubernetes is %v", err)
+	}
+	stagingRepo, err := git.PlainOpen(stagingInfo.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := kubefork.FindOpenShiftBranch(branchName, stagingRepo); err != nil {
+		return fmt.Errorf("%v, run create-fork-branches first", err)
+	}
+
+	lastPublished, err := LastPublishedKubeCommit(stagingInfo.Path, branchRef)
+	if err != nil {
+		return err
+	}
+	if _, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", "merge-base", "--is-ancestor", lastPublished, branchRef); err != nil {
+		return fmt.Errorf("last published kubernetes commit %v is not in kubernetes %q", lastPublished, branchRef)
+	}
+
+	commits, err := UnpublishedKubeCommits(kubeInfo.Path, stagingInfo.UpstreamName, lastPublished, branchRef)
+	if err != nil {
+		return err
+	}
+
+	// the branch is checked out even when there is nothing to publish so that its manifests can be rewritten
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "checkout", "-B", branchName, branchRef); err != nil {
+		return err
+	}
+	// the built-in reset and cleanoptions don't seem to work.  other weird behavior is mentioned in issues
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "reset", "--hard", branchRef); err != nil {
+		return err
+	}
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "clean", "-fd"); err != nil {
+		return err
+	}
+
+	if len(commits) == 0 {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, %q is up to date with kubernetes %v\n", stagingInfo.UpstreamName, branchName, lastPublished)
+		return nil
+	}
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, publishing %d commits after kubernetes %v\n", stagingInfo.UpstreamName, len(commits), lastPublished)
+	for _, commit := range commits {
+		if len(commit.MergedTag) > 0 {
+			if err := publishMerge(streams, kubeInfo, stagingInfo, commit); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := publishCommit(streams, kubeInfo, stagingInfo, commit.SHA); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LastPublishedKubeCommit is the kubernetes commit named by the most recent Kubernetes-commit trailer on the first-parent
+// history of a staging ref.  Upstream commits carry the trailer too, so a freshly created fork branch resumes from its
+// upstream tag.  Upstream tags merged into the staging ref, by us or by rebase-fork-branch, are not followed.
+func LastPublishedKubeCommit(stagingPath, ref string) (string, error) {
+	message, err := kubefork.CollectCmdStdout(stagingPath, "git", "log", "-1", "--first-parent", "--format=%B", "--grep=^"+kubefork.KubernetesCommitTrailer+": ", ref)
+	if err != nil {
+		return "", err
+	}
+	kubeCommit := kubefork.KubernetesCommit(message)
+	if len(kubeCommit) == 0 {
+		return "", fmt.Errorf("no commit in %q has a %s trailer", ref, kubefork.KubernetesCommitTrailer)
+	}
+	return kubeCommit, nil
+}
+
+// UnpublishedCommit is a kubernetes commit to publish to a staging repo.
+type UnpublishedCommit struct {
+	SHA string
+	// MergedTag is the upstream kubernetes tag for merges of an upstream release, like v1.16.2.
+	MergedTag string
+}
+
+// UnpublishedKubeCommits lists the fork-only kubernetes commits after lastPublished to publish to the staging repo,
+// oldest first.  These are the non-merge commits that touch the staging repo and the merges of upstream releases.
+// Commits that are also upstream were published by the upstream bot already.  Other merges, like those of pull
+// requests, only bring in commits that are published by themselves.  Changes to the manifests are left to
+// rewriteManifests.
+func UnpublishedKubeCommits(kubePath, stagingName, lastPublished, ref string) ([]UnpublishedCommit, error) {
+	notUpstream := []string{lastPublished + ".." + ref, "--not", "--remotes=upstream", "--tags=" + kubefork.UpstreamTagPattern("kubernetes")}
+
+	args := append([]string{"rev-list", "--no-merges"}, notUpstream...)
+	args = append(append(args, "--"), kubefork.StagingPathspec(stagingName)...)
+	touching, err := kubefork.CollectCmdStdout(kubePath, "git", args...)
+	if err != nil {
+		return nil, err
+	}
+	touchesStaging := map[string]bool{}
+	for _, commit := range strings.Fields(touching) {
+		touchesStaging[commit] = true
+	}
+
+	all, err := kubefork.CollectCmdStdout(kubePath, "git", append([]string{"rev-list", "--reverse", "--topo-order", "--parents"}, notUpstream...)...)
+	if err != nil {
+		return nil, err
+	}
+	ret := []UnpublishedCommit{}
+	for _, line := range strings.Split(all, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 2 && touchesStaging[fields[0]]:
+			ret = append(ret, UnpublishedCommit{SHA: fields[0]})
+		case len(fields) > 2:
+			mergedTag, err := newlyMergedUpstreamTag(kubePath, fields[0], fields[1])
+			if err != nil {
+				return nil, err
+			}
+			if len(mergedTag) > 0 {
+				ret = append(ret, UnpublishedCommit{SHA: fields[0], MergedTag: mergedTag})
+			}
+		}
+	}
+	return ret, nil
+}
+
+// newlyMergedUpstreamTag returns the upstream release a merge brings in over its first parent, or empty if it doesn't.
+func newlyMergedUpstreamTag(kubePath, merge, firstParent string) (string, error) {
+	mergedTag, err := kubefork.MergedUpstreamTag(kubePath, "kubernetes", merge)
+	if err != nil {
+		return "", err
+	}
+	previousTag, err := kubefork.MergedUpstreamTag(kubePath, "kubernetes", firstParent)
+	if err != nil {
+		return "", err
+	}
+	if mergedTag == previousTag {
+		return "", nil
+	}
+	return mergedTag, nil
+}
+
+// publishCommit applies the staging part of a kubernetes commit to the checked out staging repo and records where it
+// came from.
+func publishCommit(streams genericclioptions.IOStreams, kubeInfo, stagingInfo kubefork.RepoInfo, commit string) error {
+	stagingPath := kubefork.StagingPath(stagingInfo.UpstreamName)
+	// --keep-subject and am --keep keep subjects like "[release-1.16] ..." exactly as they are in kubernetes
+	args := []string{"format-patch", "-1", "--stdout", "--binary", "--keep-subject", "--relative=" + stagingPath, commit, "--"}
+	args = append(args, kubefork.StagingPathspec(stagingInfo.UpstreamName)...)
+	patch, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", args...)
+	if err != nil {
+		return err
+	}
+
+	patchFile, err := ioutil.TempFile("", "publish-"+stagingInfo.UpstreamName+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(patchFile.Name())
+	if _, err := patchFile.WriteString(patch); err != nil {
+		patchFile.Close()
+		return err
+	}
+	if err := patchFile.Close(); err != nil {
+		return err
+	}
+
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "am", "--3way", "--keep", "--keep-cr", patchFile.Name()); err != nil {
+		kubefork.RunCmd(streams, stagingInfo.Path, "git", "am", "--abort")
+		return fmt.Errorf("kubernetes commit %v does not apply: %v", commit, err)
+	}
+
+	message, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "log", "-1", "--format=%B")
+	if err != nil {
+		return err
+	}
+	return kubefork.RunCmd(streams, stagingInfo.Path, "git", "commit", "--amend", "--quiet", "--cleanup=verbatim", "-m", kubefork.AddKubernetesCommit(message, commit))
+}
+
+// publishMerge merges the staging tag of an upstream release into the checked out staging repo.  The result takes the
+// staging directory of the kubernetes merge, so that conflict resolutions are published too.  If the tag was merged
+// already, like rebase-fork-branch --strategy=merge does, only the differences from the kubernetes merge are committed.
+// Manifests come from the staging tag when merging and are kept otherwise, for rewriteManifests to pin siblings in.
+func publishMerge(streams genericclioptions.IOStreams, kubeInfo, stagingInfo kubefork.RepoInfo, commit UnpublishedCommit) error {
+	stagingTag := kubefork.UpstreamTag(stagingInfo.UpstreamName, strings.TrimPrefix(commit.MergedTag, kubefork.UpstreamTag("kubernetes", "")))
+	stagingRepo, err := git.PlainOpen(stagingInfo.Path)
+	if err != nil {
+		return err
+	}
+	if _, err := kubefork.FindKubeTag(stagingTag, stagingRepo); err != nil {
+		return fmt.Errorf("kubernetes %v merged %q, but there is no %q: %v", commit.SHA, commit.MergedTag, stagingTag, err)
+	}
+
+	merging := !kubefork.IsAncestor(stagingInfo.Path, stagingTag, "HEAD")
+	if merging {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, merging %q for kubernetes %v\n", stagingInfo.UpstreamName, stagingTag, commit.SHA)
+		// conflicts are fine, the staging directory of the kubernetes merge resolves them
+		mergeErr := kubefork.RunCmd(streams, stagingInfo.Path, "git", "merge", "--no-ff", "--no-commit", stagingTag)
+		if _, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "rev-parse", "-q", "--verify", "MERGE_HEAD"); err != nil {
+			return fmt.Errorf("unable to merge %q: %v", stagingTag, mergeErr)
+		}
+	}
+
+	archive, err := ioutil.TempFile("", "publish-"+stagingInfo.UpstreamName+"-")
+	if err != nil {
+		return err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+	if err := kubefork.RunCmd(streams, kubeInfo.Path, "git", "archive", "--format=tar", "-o", archive.Name(), commit.SHA+":"+kubefork.StagingPath(stagingInfo.UpstreamName)); err != nil {
+		return err
+	}
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "rm", "-r", "-q", "-f", "--ignore-unmatch", "--", "."); err != nil {
+		return err
+	}
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "tar", "-xf", archive.Name()); err != nil {
+		return err
+	}
+	manifestsFrom := "HEAD"
+	if merging {
+		manifestsFrom = stagingTag
+	}
+	for _, manifest := range kubefork.PublishingManifests {
+		os.Remove(filepath.Join(stagingInfo.Path, manifest))
+		if _, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "cat-file", "-e", manifestsFrom+":"+manifest); err != nil {
+			continue
+		}
+		if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "checkout", manifestsFrom, "--", manifest); err != nil {
+			return err
+		}
+	}
+	if err := kubefork.RunCmd(streams, stagingInfo.Path, "git", "add", "-A"); err != nil {
+		return err
+	}
+	if !merging {
+		if _, err := kubefork.CollectCmdStdout(stagingInfo.Path, "git", "diff", "--cached", "--quiet"); err == nil {
+			fmt.Fprintf(streams.Out, "For kubernetes/%v, %q is merged already and matches kubernetes %v\n", stagingInfo.UpstreamName, stagingTag, commit.SHA)
+			return nil
+		}
+	}
+
+	message, err := kubefork.CollectCmdStdout(kubeInfo.Path, "git", "log", "-1", "--format=%B", commit.SHA)
+	if err != nil {
+		return err
+	}
+	return kubefork.RunCmd(streams, stagingInfo.Path, "git", "commit", "--quiet", "--cleanup=verbatim", "-m", kubefork.AddKubernetesCommit(message, commit.SHA))
+}
@@ -0,0 +1,5 @@
+module github.com/goware/modvendor
+
+require github.com/mattn/go-zglob v0.0.2-0.20191112051448-a8912a37f9e7
+
+go 1.14
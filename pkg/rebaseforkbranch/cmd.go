@@ -0,0 +1,229 @@
+package rebaseforkbranch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type RebaseForkBranchOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	ForkOwner      string // like origin
+	ForkVersion    string // like 4.2
+	KubeVersion    string // like 1.14.0
+	NewKubeVersion string // like 1.14.6
+
+	Strategy string // merge or rebase
+	// Rename names the result for NewKubeVersion instead of KubeVersion.
+	Rename bool
+	DryRun bool
+}
+
+func NewRebaseForkBranchOptions(streams genericclioptions.IOStreams) *RebaseForkBranchOptions {
+	return &RebaseForkBranchOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		ForkOwner: "origin",
+		Strategy:  "merge",
+	}
+}
+
+// NewCmdRebaseForkBranch moves an existing fork branch to a new upstream patch release.
+func NewCmdRebaseForkBranch(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewRebaseForkBranchOptions(streams)
+	cmd := &cobra.Command{
+		Use: "rebase-fork-branch --kube-home=/path/to/k8s.io --fork-version=4.2 --kube-version=1.14.0 --new-kube-version=1.14.6 [--strategy=merge|rebase] [--rename]",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+In every repo, the upstream tag for --new-kube-version is merged into the fork branch, or the carries are rebased
+onto it with --strategy=rebase.  The carries are the commits after the newest upstream release in the fork branch,
+which is later than --kube-version if patch releases were merged before.  --new-kube-version must be later than that
+release.  The result is pushed to <branch>-proposal, never to the fork branch itself.  With
+--rename, <branch> is the fork branch name for --new-kube-version.  Pass --allow-patch-merges to verify-fork-branch to
+accept fork branches that merged patch releases this way.
+
+Conflicts are reported per repo with the conflicting files, and those repos are not pushed.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version of the existing fork branch, like 1.14.0")
+	cmd.Flags().StringVar(&o.NewKubeVersion, "new-kube-version", o.NewKubeVersion, "kube version to move to, like 1.14.6")
+	cmd.Flags().StringVar(&o.Strategy, "strategy", o.Strategy, "merge or rebase")
+	cmd.Flags().BoolVar(&o.Rename, "rename", o.Rename, "name the proposal for the new kube version")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "build the proposal branches locally, but don't push")
+
+	return cmd
+}
+
+// repoResult is the outcome for one repo.
+type repoResult struct {
+	repo             string
+	conflictingFiles []string
+	err              error
+}
+
+func (o *RebaseForkBranchOptions) Run() error {
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	if len(o.NewKubeVersion) == 0 {
+		return fmt.Errorf("must have new-kube-version")
+	}
+	if o.Strategy != "merge" && o.Strategy != "rebase" {
+		return fmt.Errorf("strategy must be merge or rebase, not %q", o.Strategy)
+	}
+	forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+	targetBranch := forkBranch
+	if o.Rename {
+		targetBranch = kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.NewKubeVersion)
+	}
+	proposalBranchName := targetBranch.BranchName() + "-proposal"
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	repoInfos, err = kubefork.ReposForKubeVersion(repoInfos, o.KubeVersion)
+	if err != nil {
+		return err
+	}
+
+	results := []repoResult{}
+	for _, currInfo := range repoInfos {
+		fmt.Fprintf(o.Streams.Out, "Check kubernetes/%v\n", currInfo.UpstreamName)
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		result := repoResult{repo: currInfo.UpstreamName}
+		result.conflictingFiles, result.err = o.moveRepo(o.Streams.Indent(), currInfo, forkBranch, proposalBranchName)
+		results = append(results, result)
+		if result.err != nil || len(result.conflictingFiles) > 0 || o.DryRun {
+			continue
+		}
+
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, pushing %q to %q\n", currInfo.UpstreamName, proposalBranchName, currInfo.Openshift.Name)
+		// proposals are ours to replace on every run
+		if err := kubefork.RunCmd(o.Streams.Indent(), currInfo.Path, "git", "push", "--force", currInfo.Openshift.Name, proposalBranchName); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(o.Streams.Out, "\nMoving %q to kube %v with %s:\n", forkBranch.BranchName(), o.NewKubeVersion, o.Strategy)
+	failed := []string{}
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			fmt.Fprintf(o.Streams.Out, "  %s: failed: %v\n", result.repo, result.err)
+			failed = append(failed, result.repo)
+		case len(result.conflictingFiles) > 0:
+			fmt.Fprintf(o.Streams.Out, "  %s: %d conflicting files\n", result.repo, len(result.conflictingFiles))
+			for _, conflictingFile := range result.conflictingFiles {
+				fmt.Fprintf(o.Streams.Out, "    %s\n", conflictingFile)
+			}
+			failed = append(failed, result.repo)
+		default:
+			fmt.Fprintf(o.Streams.Out, "  %s: clean\n", result.repo)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to move %v, those proposals were not pushed", failed)
+	}
+	return nil
+}
+
+// moveRepo builds the proposal branch in one repo, returning the conflicting files if the merge or rebase failed.
+func (o *RebaseForkBranchOptions) moveRepo(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, proposalBranchName string) ([]string, error) {
+	repo, err := git.PlainOpen(currInfo.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := kubefork.FindOpenShiftBranch(forkBranch.BranchName(), repo); err != nil {
+		return nil, err
+	}
+	newTag := kubefork.UpstreamTag(currInfo.UpstreamName, o.NewKubeVersion)
+	if _, err := kubefork.FindKubeTag(newTag, repo); err != nil {
+		return nil, err
+	}
+
+	branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+	// a branch that already merged later patch releases has their commits, so the carries start after the newest one
+	oldTag, err := kubefork.MergedUpstreamTag(currInfo.Path, currInfo.UpstreamName, branchRef)
+	if err != nil {
+		return nil, err
+	}
+	oldVersion := strings.TrimPrefix(oldTag, kubefork.UpstreamTag(currInfo.UpstreamName, ""))
+	if kubefork.CompareKubeVersions(o.NewKubeVersion, oldVersion) <= 0 {
+		return nil, fmt.Errorf("%q already has %q, --new-kube-version must be later than %v", forkBranch.BranchName(), oldTag, oldVersion)
+	}
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "checkout", "-B", proposalBranchName, branchRef); err != nil {
+		return nil, err
+	}
+	// the built-in reset and cleanoptions don't seem to work.  other weird behavior is mentioned in issues
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "reset", "--hard", branchRef); err != nil {
+		return nil, err
+	}
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", "clean", "-fd"); err != nil {
+		return nil, err
+	}
+
+	var moveErr error
+	switch o.Strategy {
+	case "merge":
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, merging %q into %q\n", currInfo.UpstreamName, newTag, forkBranch.BranchName())
+		moveErr = kubefork.RunCmd(streams, currInfo.Path, "git", "merge", "--no-ff", "--no-edit", "-m", fmt.Sprintf("Merge %s into %s", newTag, forkBranch.BranchName()), newTag)
+	case "rebase":
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, rebasing %q from %q onto %q\n", currInfo.UpstreamName, forkBranch.BranchName(), oldTag, newTag)
+		moveErr = kubefork.RunCmd(streams, currInfo.Path, "git", "rebase", "--onto", newTag, oldTag)
+	}
+	if moveErr == nil {
+		return nil, nil
+	}
+
+	conflicts, err := kubefork.CollectCmdStdout(currInfo.Path, "git", "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, err
+	}
+	if err := kubefork.RunCmd(streams, currInfo.Path, "git", o.Strategy, "--abort"); err != nil {
+		return nil, err
+	}
+	conflictingFiles := []string{}
+	for _, conflictingFile := range strings.Split(conflicts, "\n") {
+		if len(conflictingFile) > 0 {
+			conflictingFiles = append(conflictingFiles, conflictingFile)
+		}
+	}
+	if len(conflictingFiles) == 0 {
+		return nil, fmt.Errorf("%s failed without conflicts: %v", o.Strategy, moveErr)
+	}
+	return conflictingFiles, nil
+}
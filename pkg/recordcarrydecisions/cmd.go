@@ -0,0 +1,150 @@
+package recordcarrydecisions
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/makepicklist"
+	"github.com/spf13/cobra"
+)
+
+type RecordCarryDecisionsOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	Repo     string // like kubernetes, api, apimachinery, etc
+	PickList string // reviewed pick list csv
+
+	// for a single commit instead of a pick list
+	Commit string
+	Note   kubefork.CarryNote
+
+	DryRun bool
+}
+
+func NewRecordCarryDecisionsOptions(streams genericclioptions.IOStreams) *RecordCarryDecisionsOptions {
+	return &RecordCarryDecisionsOptions{
+		Streams:  streams,
+		KubeHome: "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:     "kubernetes",
+	}
+}
+
+// NewCmdRecordCarryDecisions stores pick list review decisions as git notes on the carries.
+func NewCmdRecordCarryDecisions(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewRecordCarryDecisionsOptions(streams)
+	cmd := &cobra.Command{
+		Use: "record-carry-decisions --kube-home=/path/to/k8s.io --repo=kubernetes (--pick-list=reviewed.csv | --commit=<sha> --decision=drop --rationale=\"fixed upstream\")",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Review decisions are stored as git notes in refs/notes/carries on the fork commits, as "key: value" lines for
+decision, upstream-pr, owner, and rationale.  Every row of a reviewed --pick-list is recorded, or a single --commit.
+Values that are already recorded are kept unless they are given again.  The notes are pushed to the openshift remote
+and make-pick-list uses them to pre-fill the next pick list.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.PickList, "pick-list", o.PickList, "reviewed pick list csv to record the decisions of")
+	cmd.Flags().StringVar(&o.Commit, "commit", o.Commit, "fork commit to record a decision for")
+	cmd.Flags().StringVar(&o.Note.Decision, "decision", o.Note.Decision, "pick, drop, or squash")
+	cmd.Flags().StringVar(&o.Note.UpstreamPR, "upstream-pr", o.Note.UpstreamPR, "upstream pull request that replaces the carry")
+	cmd.Flags().StringVar(&o.Note.Owner, "owner", o.Note.Owner, "team that owns the carry")
+	cmd.Flags().StringVar(&o.Note.Rationale, "rationale", o.Note.Rationale, "why the decision was made")
+	cmd.Flags().BoolVar(&o.DryRun, "dry-run", o.DryRun, "record the notes locally, but don't push")
+
+	return cmd
+}
+
+func (o *RecordCarryDecisionsOptions) Run() error {
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if (len(o.PickList) == 0) == (len(o.Commit) == 0) {
+		return fmt.Errorf("must have exactly one of pick-list or commit")
+	}
+	if len(o.Commit) > 0 && o.Note == (kubefork.CarryNote{}) {
+		return fmt.Errorf("must have decision, upstream-pr, owner, or rationale")
+	}
+
+	notes := map[string]kubefork.CarryNote{}
+	commits := []string{}
+	if len(o.Commit) > 0 {
+		notes[o.Commit] = o.Note
+		commits = append(commits, o.Commit)
+	} else {
+		_, rows, err := makepicklist.ReadPickList(o.PickList)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if len(row.ForkCommit) == 0 {
+				continue
+			}
+			notes[row.ForkCommit] = kubefork.CarryNote{
+				Decision:   row.SuggestedAction,
+				UpstreamPR: row.UpstreamPR,
+				Owner:      strings.Join(row.Owner, " "),
+				Rationale:  row.Rationale,
+			}
+			commits = append(commits, row.ForkCommit)
+		}
+	}
+	for _, commit := range commits {
+		note := notes[commit]
+		if len(note.Decision) == 0 {
+			continue
+		}
+		decision, err := makepicklist.NormalizeDecision(note.Decision)
+		if err != nil {
+			return fmt.Errorf("%v: %v", commit, err)
+		}
+		note.Decision = decision
+		notes[commit] = note
+	}
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		// this merges the notes already pushed, so that ours go on top of them
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, recording decisions for %d carries\n", currInfo.UpstreamName, len(commits))
+		for _, commit := range commits {
+			if err := kubefork.WriteCarryNote(o.Streams.Indent(), currInfo.Path, commit, notes[commit]); err != nil {
+				return err
+			}
+		}
+		if o.DryRun {
+			return nil
+		}
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, pushing %q to %q\n", currInfo.UpstreamName, kubefork.CarryNotesRef, currInfo.Openshift.Name)
+		return kubefork.PushCarryNotes(o.Streams.Indent(), currInfo)
+	}
+
+	return fmt.Errorf("unknown repo %q", o.Repo)
+}
@@ -0,0 +1,281 @@
+package summarizeforkbranch
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type SummarizeForkBranchOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+	OutFile  string
+
+	Repo                string // like kubernetes, api, apimachinery, etc
+	ForkOwner           string // like origin
+	ForkVersion         string // like 4.3
+	KubeVersion         string // like 1.16.0
+	PreviousForkVersion string // like 4.2
+	PreviousKubeVersion string // like 1.14.0
+
+	Owners      bool
+	TeamMapping string
+}
+
+func NewSummarizeForkBranchOptions(streams genericclioptions.IOStreams) *SummarizeForkBranchOptions {
+	return &SummarizeForkBranchOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:      "kubernetes",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdSummarizeForkBranch writes a Markdown summary of the carries of a fork branch for rebase PRs and release notes.
+func NewCmdSummarizeForkBranch(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewSummarizeForkBranchOptions(streams)
+	cmd := &cobra.Command{
+		Use: "summarize-fork-branch --kube-home=/path/to/k8s.io --fork-owner=origin --fork-version=4.3 --kube-version=1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+The summary names the upstream tag the fork branch is based on and lists its carries grouped by kind, from the
+UPSTREAM: prefix of their subject, and then by owner.  Picks of upstream pull requests link to the pull request.
+
+Owners come from the decisions recorded by record-carry-decisions.  With --owners, carries without a recorded owner
+fall back to the approvers of the nearest OWNERS files in the upstream base tag, and --team-mapping is a file of
+"path/prefix team" lines used before OWNERS files, like make-pick-list.
+
+The carries added and dropped since the previous fork branch are listed as well.  Without --previous-fork-version and
+--previous-kube-version, the previous fork branch is the closest earlier fork branch of the same owner.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.3")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.16.0")
+	cmd.Flags().StringVar(&o.PreviousForkVersion, "previous-fork-version", o.PreviousForkVersion, "previous fork version to compare with, like 4.2.  Defaults to the closest earlier fork branch")
+	cmd.Flags().StringVar(&o.PreviousKubeVersion, "previous-kube-version", o.PreviousKubeVersion, "previous kube version to compare with, like 1.14.0")
+	cmd.Flags().StringVar(&o.OutFile, "out-file", o.OutFile, "markdown file to write to, stdout if empty")
+	cmd.Flags().BoolVar(&o.Owners, "owners", o.Owners, "fall back to the approvers of the nearest upstream OWNERS file for carries without a recorded owner")
+	cmd.Flags().StringVar(&o.TeamMapping, "team-mapping", o.TeamMapping, "file of \"path/prefix team\" lines to find owners from before falling back to OWNERS files")
+
+	return cmd
+}
+
+func (o *SummarizeForkBranchOptions) Run() error {
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	if (len(o.PreviousForkVersion) == 0) != (len(o.PreviousKubeVersion) == 0) {
+		return fmt.Errorf("must have both previous-fork-version and previous-kube-version, or neither")
+	}
+
+	// progress goes to stderr so that the summary itself can be piped
+	progressStreams := genericclioptions.IOStreams{In: o.Streams.In, Out: o.Streams.ErrOut, ErrOut: o.Streams.ErrOut}
+	repoInfos, err := kubefork.GetAllKubeRepos(progressStreams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(progressStreams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		summary, err := o.summarize(progressStreams.Indent(), currInfo)
+		if err != nil {
+			return err
+		}
+
+		if len(o.OutFile) == 0 {
+			summary.writeMarkdown(o.Streams.Out)
+			return nil
+		}
+		file, err := os.Create(o.OutFile)
+		if err != nil {
+			return err
+		}
+		summary.writeMarkdown(file)
+		return file.Close()
+	}
+
+	return fmt.Errorf("missing repo %q", o.Repo)
+}
+
+func (o *SummarizeForkBranchOptions) summarize(streams genericclioptions.IOStreams, currInfo kubefork.RepoInfo) (*forkBranchSummary, error) {
+	repo, err := git.PlainOpen(currInfo.Path)
+	if err != nil {
+		return nil, err
+	}
+	branch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+	if _, err := kubefork.FindOpenShiftBranch(branch.BranchName(), repo); err != nil {
+		return nil, err
+	}
+	baseTag, err := kubefork.MergedUpstreamTag(currInfo.Path, currInfo.UpstreamName, kubefork.OpenShiftBranchRef(branch))
+	if err != nil {
+		return nil, err
+	}
+
+	carries, err := loadCarries(currInfo, branch)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := kubefork.NewCarryNoteMatcher(currInfo.Path)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := o.carryOwners(repo, currInfo.Path, baseTag, carries, notes)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &forkBranchSummary{
+		repo:    currInfo.UpstreamName,
+		branch:  branch.BranchName(),
+		baseTag: baseTag,
+		carries: carries,
+		owners:  owners,
+		notes:   notes,
+	}
+
+	prevBranch, found, err := o.previousBranch(repo, branch)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		fmt.Fprintf(streams.Out, "For kubernetes/%v, no fork branch of %q before %q, skipping comparison\n", currInfo.UpstreamName, branch.ForkOwner, branch.BranchName())
+		return summary, nil
+	}
+	fmt.Fprintf(streams.Out, "For kubernetes/%v, comparing with %q\n", currInfo.UpstreamName, prevBranch.BranchName())
+	prevCarries, err := loadCarries(currInfo, prevBranch)
+	if err != nil {
+		return nil, err
+	}
+	comparison := kubefork.CompareCarries(prevCarries, carries)
+	summary.prevBranch = prevBranch.BranchName()
+	summary.comparison = &comparison
+	return summary, nil
+}
+
+// previousBranch is the fork branch to compare with, either from the flags or the closest earlier fork branch of the
+// same owner.
+func (o *SummarizeForkBranchOptions) previousBranch(repo *git.Repository, branch kubefork.ForkBranchInfo) (kubefork.ForkBranchInfo, bool, error) {
+	if len(o.PreviousForkVersion) > 0 {
+		prevBranch := kubefork.NewForkBranch(o.ForkOwner, o.PreviousForkVersion, o.PreviousKubeVersion)
+		if _, err := kubefork.FindOpenShiftBranch(prevBranch.BranchName(), repo); err != nil {
+			return kubefork.ForkBranchInfo{}, false, err
+		}
+		return prevBranch, true, nil
+	}
+
+	forkBranches, err := kubefork.FindOpenShiftForkBranches(repo)
+	if err != nil {
+		return kubefork.ForkBranchInfo{}, false, err
+	}
+	var prevBranch kubefork.ForkBranchInfo
+	found := false
+	// fork branches are sorted, so the last earlier one is the closest
+	for _, forkBranch := range forkBranches {
+		if forkBranch.ForkOwner != branch.ForkOwner || !earlierForkBranch(forkBranch, branch) {
+			continue
+		}
+		prevBranch = forkBranch
+		found = true
+	}
+	return prevBranch, found, nil
+}
+
+func earlierForkBranch(a, b kubefork.ForkBranchInfo) bool {
+	if c := kubefork.CompareVersions(a.ForkVersion, b.ForkVersion); c != 0 {
+		return c < 0
+	}
+	return kubefork.CompareVersions(a.KubeVersion, b.KubeVersion) < 0
+}
+
+// carryOwners returns the owners of each carry by SHA.  Recorded owners win, then OWNERS files and the team mapping if
+// requested.
+func (o *SummarizeForkBranchOptions) carryOwners(repo *git.Repository, repoPath, baseTag string, carries []kubefork.Carry, notes *kubefork.CarryNoteMatcher) (map[string][]string, error) {
+	var resolver *kubefork.OwnersResolver
+	if o.ownersEnabled() {
+		// upstream OWNERS files of the version the fork branch is based on
+		tagRef, err := kubefork.FindKubeTag(baseTag, repo)
+		if err != nil {
+			return nil, err
+		}
+		tagCommit, err := kubefork.ReferenceCommit(repo, tagRef)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := tagCommit.Tree()
+		if err != nil {
+			return nil, err
+		}
+		if resolver, err = kubefork.NewOwnersResolver(o.TeamMapping, tree); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := map[string][]string{}
+	for _, carry := range carries {
+		if note, ok := notes.Find(carry); ok && len(note.Owner) > 0 {
+			ret[carry.SHA] = strings.Fields(note.Owner)
+			continue
+		}
+		if resolver == nil {
+			continue
+		}
+		files, err := kubefork.CollectCmdStdout(repoPath, "git", "show", "--format=", "--name-only", carry.SHA)
+		if err != nil {
+			return nil, err
+		}
+		if ret[carry.SHA], err = resolver.Owners(strings.Fields(files)); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (o *SummarizeForkBranchOptions) ownersEnabled() bool {
+	return o.Owners || len(o.TeamMapping) > 0
+}
+
+func loadCarries(currInfo kubefork.RepoInfo, branch kubefork.ForkBranchInfo) ([]kubefork.Carry, error) {
+	commits, err := kubefork.ListCarries(currInfo.Path, currInfo.UpstreamName, branch)
+	if err != nil {
+		return nil, err
+	}
+	return kubefork.LoadCarries(currInfo.Path, commits)
+}
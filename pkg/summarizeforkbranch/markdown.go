@@ -0,0 +1,139 @@
+package summarizeforkbranch
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+)
+
+// carryKinds are the kinds of carries in the order they are summarized.
+var carryKinds = []struct {
+	kind  string
+	title string
+}{
+	{kind: kubefork.CarryKindUpstream, title: "Upstream pull requests"},
+	{kind: kubefork.CarryKindCarry, title: "Carries"},
+	{kind: kubefork.CarryKindDrop, title: "Drops"},
+	{kind: kubefork.CarryKindUnknown, title: "Other commits"},
+}
+
+const unowned = "<none>"
+
+type forkBranchSummary struct {
+	repo    string
+	branch  string
+	baseTag string
+	carries []kubefork.Carry
+	// owners are keyed by carry SHA
+	owners map[string][]string
+	notes  *kubefork.CarryNoteMatcher
+
+	// prevBranch and comparison are empty when there is no earlier fork branch
+	prevBranch string
+	comparison *kubefork.CarryComparison
+}
+
+func (s forkBranchSummary) writeMarkdown(out io.Writer) {
+	fmt.Fprintf(out, "# %s\n\n", s.branch)
+	fmt.Fprintf(out, "kubernetes/%s based on upstream [`%s`](https://github.com/kubernetes/%s/tree/%s) with %d carries.\n",
+		s.repo, s.baseTag, s.repo, s.baseTag, len(s.carries))
+
+	if s.comparison != nil {
+		fmt.Fprintf(out, "\n## Changes since %s\n\n", s.prevBranch)
+		fmt.Fprintf(out, "%d added, %d dropped, %d modified, %d unchanged.\n",
+			len(s.comparison.Added), len(s.comparison.Dropped), len(s.comparison.Modified), len(s.comparison.Unchanged))
+		if len(s.comparison.Added) > 0 {
+			fmt.Fprintf(out, "\n### Added\n\n")
+			for _, carry := range s.comparison.Added {
+				fmt.Fprintf(out, "- %s\n", s.carryLine(carry))
+			}
+		}
+		if len(s.comparison.Dropped) > 0 {
+			fmt.Fprintf(out, "\n### Dropped\n\n")
+			for _, carry := range s.comparison.Dropped {
+				fmt.Fprintf(out, "- %s\n", s.carryLine(carry))
+			}
+		}
+	}
+
+	byKind := map[string][]kubefork.Carry{}
+	for _, carry := range s.carries {
+		kind := kubefork.ParseCarrySubject(carry.Subject).Kind
+		byKind[kind] = append(byKind[kind], carry)
+	}
+	for _, carryKind := range carryKinds {
+		carries := byKind[carryKind.kind]
+		if len(carries) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n## %s (%d)\n", carryKind.title, len(carries))
+
+		// carries owned by several owners are listed under each
+		byOwner := map[string][]kubefork.Carry{}
+		for _, carry := range carries {
+			owners := s.owners[carry.SHA]
+			if len(owners) == 0 {
+				owners = []string{unowned}
+			}
+			for _, owner := range owners {
+				byOwner[owner] = append(byOwner[owner], carry)
+			}
+		}
+		for _, owner := range sortedOwners(byOwner) {
+			fmt.Fprintf(out, "\n### %s\n\n", owner)
+			for _, carry := range byOwner[owner] {
+				fmt.Fprintf(out, "- %s\n", s.carryLine(carry))
+			}
+		}
+	}
+}
+
+// carryLine is the description of a carry, with a link to the upstream pull request it picks or was replaced by.
+func (s forkBranchSummary) carryLine(carry kubefork.Carry) string {
+	subject := kubefork.ParseCarrySubject(carry.Subject)
+	line := fmt.Sprintf("%s (`%s`)", subject.Description, short(carry.SHA))
+	if subject.Kind == kubefork.CarryKindUpstream {
+		return fmt.Sprintf("%s %s", pullRequestLink(subject.PR), line)
+	}
+	if note, ok := s.notes.Find(carry); ok && len(note.UpstreamPR) > 0 {
+		return fmt.Sprintf("%s, upstream in %s", line, pullRequestLink(note.UpstreamPR))
+	}
+	return line
+}
+
+var pullRequestNumberRegex = regexp.MustCompile(`^#?([0-9]+)$`)
+
+// pullRequestLink links a kubernetes/kubernetes pull request number.  Anything else, like a full URL, is left alone.
+func pullRequestLink(pr string) string {
+	matches := pullRequestNumberRegex.FindStringSubmatch(strings.TrimSpace(pr))
+	if matches == nil {
+		return pr
+	}
+	return fmt.Sprintf("[#%s](https://github.com/kubernetes/kubernetes/pull/%s)", matches[1], matches[1])
+}
+
+// sortedOwners sorts owners by name, with carries nobody owns last.
+func sortedOwners(byOwner map[string][]kubefork.Carry) []string {
+	ret := []string{}
+	for owner := range byOwner {
+		if owner != unowned {
+			ret = append(ret, owner)
+		}
+	}
+	sort.Strings(ret)
+	if _, ok := byOwner[unowned]; ok {
+		ret = append(ret, unowned)
+	}
+	return ret
+}
+
+func short(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
@@ -0,0 +1,343 @@
+package validatepicklist
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/carrydeps"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/makepicklist"
+	"github.com/spf13/cobra"
+)
+
+type ValidatePickListOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+	PickList string
+	OutFile  string
+	PatchDir string
+
+	Repo                string // like kubernetes, api, apimachinery, etc
+	ForkOwner           string // like origin
+	PreviousForkVersion string // like 4.1
+	PreviousKubeVersion string // like 1.14.3
+
+	Dependencies bool
+}
+
+func NewValidatePickListOptions(streams genericclioptions.IOStreams) *ValidatePickListOptions {
+	return &ValidatePickListOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		Repo:      "kubernetes",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdValidatePickList checks a reviewed pick list against the fork branch it was made from.
+func NewCmdValidatePickList(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewValidatePickListOptions(streams)
+	cmd := &cobra.Command{
+		Use: "validate-pick-list --kube-home=/path/to/k8s.io --pick-list=reviewed.csv --repo=kubernetes --previous-fork-version=4.1 --previous-kube-version=1.13.4",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+A pick list edited in a spreadsheet is checked against the columns make-pick-list writes and against the carries of
+the previous fork branch it was made from.  Errors are:
+ 1. a missing header row, or missing or repeated columns
+ 2. fork-commits that are empty, don't exist, or aren't on the previous fork branch
+ 3. carries missing from the list, or listed more than once
+ 4. decisions that aren't a spelling of pick, drop, or squash, and squashes into a commit that isn't picked
+Reordered rows and added columns are warnings.
+
+--dependencies also warns about kept carries that depend on dropped or later carries, found by blaming the lines each
+carry changes like make-pick-list --dependencies does.  It is slow on large forks.
+
+With --out-file, a valid pick list is written back with full commit SHAs and decisions spelled pick, drop, or squash.
+With --patch-dir, the carries of a valid pick list that aren't dropped are written as a patch series in pick list
+order, the way make-pick-list --patch-dir does, for apply-patch-series.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.PickList, "pick-list", o.PickList, "reviewed pick list csv to validate")
+	cmd.Flags().StringVar(&o.OutFile, "out-file", o.OutFile, "csv file to write the normalized pick list to")
+	cmd.Flags().StringVar(&o.PatchDir, "patch-dir", o.PatchDir, "directory to write the carries that aren't dropped to as a patch series")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "like kubernetes, apimachinery, client-go")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.PreviousForkVersion, "previous-fork-version", o.PreviousForkVersion, "fork version the pick list was made from, like 4.1")
+	cmd.Flags().StringVar(&o.PreviousKubeVersion, "previous-kube-version", o.PreviousKubeVersion, "kube version the pick list was made from, like 1.13.4")
+	cmd.Flags().BoolVar(&o.Dependencies, "dependencies", o.Dependencies, "warn about kept carries that depend on dropped or later carries")
+
+	return cmd
+}
+
+func (o *ValidatePickListOptions) Run() error {
+	if len(o.PickList) == 0 {
+		return fmt.Errorf("must have pick-list")
+	}
+	if len(o.Repo) == 0 {
+		return fmt.Errorf("must have repo")
+	}
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.PreviousForkVersion) == 0 {
+		return fmt.Errorf("must have previous-fork-version")
+	}
+	if len(o.PreviousKubeVersion) == 0 {
+		return fmt.Errorf("must have previous-kube-version")
+	}
+
+	header, rows, err := makepicklist.ReadPickList(o.PickList)
+	if err != nil {
+		return err
+	}
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	for _, currInfo := range repoInfos {
+		if currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		prevBranch := kubefork.NewForkBranch(o.ForkOwner, o.PreviousForkVersion, o.PreviousKubeVersion)
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, validating %q against %q\n", currInfo.UpstreamName, o.PickList, prevBranch.BranchName())
+		problems, err := validate(currInfo.Path, currInfo.UpstreamName, prevBranch, header, rows, o.Dependencies)
+		if err != nil {
+			return err
+		}
+		if errors := problems.write(o.Streams.Out); errors > 0 {
+			return fmt.Errorf("%q has %d errors", o.PickList, errors)
+		}
+		fmt.Fprintf(o.Streams.Out, "%q is valid\n", o.PickList)
+
+		if len(o.OutFile) > 0 {
+			if err := makepicklist.WritePickList(o.OutFile, header, rows); err != nil {
+				return err
+			}
+		}
+		if len(o.PatchDir) > 0 {
+			if err := makepicklist.WritePatchSeries(o.Streams.Indent(), currInfo.Path, o.PatchDir, rows); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown repo %q", o.Repo)
+}
+
+type problem struct {
+	// line is the csv line, 0 for problems with the whole list
+	line    int
+	isError bool
+	message string
+}
+
+type problems []problem
+
+func (p *problems) errorf(line int, format string, args ...interface{}) {
+	*p = append(*p, problem{line: line, isError: true, message: fmt.Sprintf(format, args...)})
+}
+
+func (p *problems) warningf(line int, format string, args ...interface{}) {
+	*p = append(*p, problem{line: line, message: fmt.Sprintf(format, args...)})
+}
+
+// write prints the problems and returns how many are errors.
+func (p problems) write(out io.Writer) int {
+	errors := 0
+	for _, curr := range p {
+		severity := "WARNING"
+		if curr.isError {
+			severity = "ERROR"
+			errors++
+		}
+		location := "pick list"
+		if curr.line > 0 {
+			location = fmt.Sprintf("line %d", curr.line)
+		}
+		fmt.Fprintf(out, "%s: %s: %s\n", severity, location, curr.message)
+	}
+	return errors
+}
+
+var fullSHARegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// validate checks the pick list and normalizes the commits and decisions of rows in place.
+func validate(repoPath, upstreamName string, prevBranch kubefork.ForkBranchInfo, header []string, rows []makepicklist.PickListRow, dependencies bool) (problems, error) {
+	ret := problems{}
+
+	// the header is line 1, so rows start on line 2
+	line := func(i int) int {
+		return i + 2
+	}
+
+	known := map[string]bool{}
+	for _, column := range makepicklist.PickListHeader {
+		known[column] = true
+	}
+	for _, column := range (&makepicklist.MakePickListOptions{TrialApply: true, Metadata: true, Owners: true, Dependencies: true}).Columns() {
+		known[column] = true
+	}
+	seenColumns := map[string]bool{}
+	for _, column := range header {
+		switch {
+		case seenColumns[column]:
+			ret.errorf(0, "column %q is repeated", column)
+		case !known[column]:
+			ret.warningf(0, "column %q is not written by make-pick-list, it is kept as is", column)
+		}
+		seenColumns[column] = true
+	}
+	for _, column := range []string{makepicklist.ColumnDescription, makepicklist.ColumnSuggestedAction} {
+		if !seenColumns[column] {
+			ret.errorf(0, "missing column %q", column)
+		}
+	}
+
+	carries, err := kubefork.ListCarries(repoPath, upstreamName, prevBranch)
+	if err != nil {
+		return nil, err
+	}
+	carryIndex := map[string]int{}
+	for i, carry := range carries {
+		carryIndex[carry] = i
+	}
+
+	branchRef := kubefork.OpenShiftBranchRef(prevBranch)
+	rowsByCommit := map[string]int{}
+	lastCarryIndex := -1
+	for i := range rows {
+		row := &rows[i]
+		if len(row.ForkCommit) == 0 {
+			ret.errorf(line(i), "missing %s", makepicklist.ColumnForkCommit)
+			continue
+		}
+
+		if !fullSHARegex.MatchString(row.ForkCommit) {
+			resolved, ok := resolveCommit(repoPath, row.ForkCommit)
+			if !ok {
+				ret.errorf(line(i), "%s %q is not a commit", makepicklist.ColumnForkCommit, row.ForkCommit)
+				continue
+			}
+			row.ForkCommit = resolved
+		}
+		if len(row.RelatedCommit) > 0 && !fullSHARegex.MatchString(row.RelatedCommit) {
+			if resolved, ok := resolveCommit(repoPath, row.RelatedCommit); ok {
+				row.RelatedCommit = resolved
+			}
+		}
+		if previous, ok := rowsByCommit[row.ForkCommit]; ok {
+			ret.errorf(line(i), "%s is already listed on line %d", row.ForkCommit, line(previous))
+			continue
+		}
+		rowsByCommit[row.ForkCommit] = i
+
+		decision, err := makepicklist.NormalizeDecision(row.SuggestedAction)
+		if err != nil {
+			ret.errorf(line(i), "%s: %v", row.ForkCommit, err)
+		} else {
+			row.SuggestedAction = decision
+		}
+
+		index, isCarry := carryIndex[row.ForkCommit]
+		switch {
+		case isCarry && row.SuggestedAction == makepicklist.ActionSquash:
+			// make-pick-list moves fixups right after the carry they squash into
+		case isCarry:
+			// only where the order breaks, so that one moved row is one warning
+			if index < lastCarryIndex {
+				ret.warningf(line(i), "%s is out of order, %q lists carries oldest first", row.ForkCommit, prevBranch.BranchName())
+			}
+			lastCarryIndex = index
+		case !kubefork.IsAncestor(repoPath, row.ForkCommit, branchRef):
+			ret.errorf(line(i), "%s is not on %q", row.ForkCommit, prevBranch.BranchName())
+		default:
+			ret.warningf(line(i), "%s is on %q, but is not a carry", row.ForkCommit, prevBranch.BranchName())
+		}
+	}
+
+	for _, carry := range carries {
+		if _, ok := rowsByCommit[carry]; !ok {
+			ret.errorf(0, "carry %s is missing", carry)
+		}
+	}
+
+	for i, row := range rows {
+		if row.SuggestedAction != makepicklist.ActionSquash {
+			continue
+		}
+		target, ok := rowsByCommit[row.RelatedCommit]
+		switch {
+		case len(row.RelatedCommit) == 0:
+			ret.errorf(line(i), "%s is squashed, but has no %s to squash into", row.ForkCommit, makepicklist.ColumnRelatedCommit)
+		case !ok:
+			ret.errorf(line(i), "%s is squashed into %s, which is not in the pick list", row.ForkCommit, row.RelatedCommit)
+		case rows[target].SuggestedAction == makepicklist.ActionDrop:
+			ret.errorf(line(i), "%s is squashed into %s, which is dropped", row.ForkCommit, row.RelatedCommit)
+		}
+	}
+
+	if !dependencies {
+		return ret, nil
+	}
+
+	// dropping or reordering carries that others build on makes those others conflict
+	since, err := kubefork.MergedUpstreamTag(repoPath, upstreamName, branchRef)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := carrydeps.Build(repoPath, since, carries)
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if _, isCarry := carryIndex[row.ForkCommit]; !isCarry || row.SuggestedAction == makepicklist.ActionDrop {
+			continue
+		}
+		for _, dependency := range graph.DependsOn[row.ForkCommit] {
+			dependencyRow, ok := rowsByCommit[dependency]
+			switch {
+			case !ok:
+			case rows[dependencyRow].SuggestedAction == makepicklist.ActionDrop:
+				ret.warningf(line(i), "%s depends on %s, which is dropped", row.ForkCommit, dependency)
+			case dependencyRow > i:
+				ret.warningf(line(i), "%s depends on %s, which is listed after it on line %d", row.ForkCommit, dependency, line(dependencyRow))
+			}
+		}
+	}
+
+	return ret, nil
+}
+
+// resolveCommit expands abbreviated commits, which spreadsheets are prone to making.
+func resolveCommit(repoPath, commit string) (string, bool) {
+	resolved, err := kubefork.CollectCmdStdout(repoPath, "git", "rev-parse", "--verify", "--quiet", commit+"^{commit}")
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(resolved), true
+}
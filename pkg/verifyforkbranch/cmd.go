@@ -0,0 +1,183 @@
+package verifyforkbranch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+)
+
+type VerifyForkBranchOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	ForkOwner   string // like origin
+	ForkVersion string // like 4.2
+	KubeVersion string // like 1.15.0
+
+	AllowPatchMerges bool
+}
+
+func NewVerifyForkBranchOptions(streams genericclioptions.IOStreams) *VerifyForkBranchOptions {
+	return &VerifyForkBranchOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdVerifyForkBranch checks that a fork branch exists, correctly based, in every repo that should have it.
+func NewCmdVerifyForkBranch(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewVerifyForkBranchOptions(streams)
+	cmd := &cobra.Command{
+		Use: "verify-fork-branch --kube-home=/path/to/k8s.io --fork-owner=origin --fork-version=4.3 --kube-version=1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+Every repo that was in kubernetes/staging at the kube version must have the fork branch on its openshift remote and
+the most recent upstream tag in the branch's history must be exactly the upstream tag for the kube version.  Missing and
+misbased branches are reported and the command fails.
+
+--allow-patch-merges also accepts branches that merged later patch releases of the same minor version, like
+rebase-fork-branch --strategy=merge does, and reports them.  Branches with a later minor release merged are still
+misbased.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.14.1")
+	cmd.Flags().BoolVar(&o.AllowPatchMerges, "allow-patch-merges", o.AllowPatchMerges, "accept branches that merged later upstream patch releases of the kube version")
+
+	return cmd
+}
+
+func (o *VerifyForkBranchOptions) Run() error {
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	repoInfos, err = kubefork.ReposForKubeVersion(repoInfos, o.KubeVersion)
+	if err != nil {
+		return err
+	}
+
+	missing := []string{}
+	misbased := []string{}
+	for _, currInfo := range repoInfos {
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+	}
+	for _, currInfo := range repoInfos {
+		problem, laterTags, err := verifyRepo(currInfo, forkBranch, o.AllowPatchMerges)
+		if err != nil {
+			return err
+		}
+		switch problem {
+		case "":
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, branch %q is ok\n", currInfo.UpstreamName, forkBranch.BranchName())
+			if len(laterTags) > 0 {
+				fmt.Fprintf(o.Streams.Indent().Out, "merged later upstream patch releases: %v\n", strings.Join(laterTags, " "))
+			}
+		case missingBranch:
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, branch %q is missing\n", currInfo.UpstreamName, forkBranch.BranchName())
+			missing = append(missing, currInfo.UpstreamName)
+		default:
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, branch %q is misbased: %v\n", currInfo.UpstreamName, forkBranch.BranchName(), problem)
+			misbased = append(misbased, currInfo.UpstreamName)
+		}
+	}
+
+	if len(missing) > 0 || len(misbased) > 0 {
+		return fmt.Errorf("branch %q is missing in %v and misbased in %v", forkBranch.BranchName(), missing, misbased)
+	}
+	return nil
+}
+
+const missingBranch = "missing"
+
+// verifyRepo returns a description of what is wrong with the branch in the repo, or empty if nothing is.  It also
+// returns the upstream patch releases merged into the branch after the one it was created from, which are only fine
+// if allowPatchMerges is set.
+func verifyRepo(currInfo kubefork.RepoInfo, forkBranch kubefork.ForkBranchInfo, allowPatchMerges bool) (string, []string, error) {
+	repo, err := git.PlainOpen(currInfo.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := kubefork.FindOpenShiftBranch(forkBranch.BranchName(), repo); err != nil {
+		return missingBranch, nil, nil
+	}
+
+	branchRef := kubefork.OpenShiftBranchRef(forkBranch)
+	expectedTag := kubefork.UpstreamTag(currInfo.UpstreamName, forkBranch.KubeVersion)
+	if _, err := kubefork.FindKubeTag(expectedTag, repo); err != nil {
+		return fmt.Sprintf("missing upstream tag %q", expectedTag), nil, nil
+	}
+	if !kubefork.IsAncestor(currInfo.Path, expectedTag, branchRef) {
+		actualTag, err := kubefork.MergedUpstreamTag(currInfo.Path, currInfo.UpstreamName, branchRef)
+		if err != nil {
+			return err.Error(), nil, nil
+		}
+		return fmt.Sprintf("%q is not in its history, based on %q", expectedTag, actualTag), nil, nil
+	}
+
+	mergedTags, err := kubefork.MergedUpstreamTags(currInfo.Path, currInfo.UpstreamName, branchRef)
+	if err != nil {
+		return "", nil, err
+	}
+	prefix := kubefork.UpstreamTag(currInfo.UpstreamName, "")
+	laterTags := []string{}
+	for _, tag := range mergedTags {
+		version := strings.TrimPrefix(tag, prefix)
+		// upstream tags the next alpha where the release branch was cut, so later pre-releases are expected
+		if kubefork.IsPreRelease(version) || kubefork.CompareKubeVersions(version, forkBranch.KubeVersion) <= 0 {
+			continue
+		}
+		if minorVersion(version) != minorVersion(forkBranch.KubeVersion) {
+			return fmt.Sprintf("merged %q from a later minor release than %q", tag, expectedTag), nil, nil
+		}
+		laterTags = append(laterTags, tag)
+	}
+	if len(laterTags) > 0 && !allowPatchMerges {
+		return fmt.Sprintf("merged %q after %q, which --allow-patch-merges accepts", laterTags[len(laterTags)-1], expectedTag), nil, nil
+	}
+	return "", laterTags, nil
+}
+
+// minorVersion is the major.minor of a kube version like 1.16.2.
+func minorVersion(kubeVersion string) string {
+	parts := strings.SplitN(kubeVersion, ".", 3)
+	if len(parts) < 2 {
+		return kubeVersion
+	}
+	return parts[0] + "." + parts[1]
+}
@@ -0,0 +1,220 @@
+package verifystaging
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/openshift/kube-publishing-setup-bot/pkg/genericclioptions"
+	"github.com/openshift/kube-publishing-setup-bot/pkg/kubefork"
+	"github.com/spf13/cobra"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+type VerifyStagingOptions struct {
+	Streams genericclioptions.IOStreams
+
+	KubeHome string
+
+	ForkOwner   string // like origin
+	ForkVersion string // like 4.2
+	KubeVersion string // like 1.15.0
+
+	Repo string // optional, only verify this staging repo
+}
+
+func NewVerifyStagingOptions(streams genericclioptions.IOStreams) *VerifyStagingOptions {
+	return &VerifyStagingOptions{
+		Streams:   streams,
+		KubeHome:  "kube-publishing-setup-bot.local/src/k8s.io",
+		ForkOwner: "origin",
+	}
+}
+
+// NewCmdVerifyStaging checks that the staging fork branches have the same content as staging in the kubernetes fork.
+func NewCmdVerifyStaging(streams genericclioptions.IOStreams) *cobra.Command {
+	o := NewVerifyStagingOptions(streams)
+	cmd := &cobra.Command{
+		Use: "verify-staging --kube-home=/path/to/k8s.io --fork-owner=origin --fork-version=4.3 --kube-version=1.16.0",
+		Long: `
+--kube-home must point to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.
+
+This command will auto-create it if necessary and auto-create the repos inside of it.
+ 1. upstream will be the remote for k8s - git@github.com:/kubernetes/<repo>.git
+ 2. openshfit will be the remove for openshift forks - git@github.com:/openshift/kubernetes-<repo>.git
+
+For every staging repo, the tree of staging/src/k8s.io/<repo> on the openshift/kubernetes fork branch is compared with
+the root tree of the same fork branch in openshift/kubernetes-<repo>.  The manifests that publishing rewrites (go.mod,
+go.sum, Godeps/Godeps.json) are ignored.  Every path that differs is listed and the command fails.
+`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := o.Run(); err != nil {
+				panic(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&o.KubeHome, "kube-home", o.KubeHome, "points to /path/to/k8s.io where /path/to/k8s.io/{kubernetes,api,apimachinery,etcd} should be.")
+	cmd.Flags().StringVar(&o.ForkOwner, "fork-owner", o.ForkOwner, "like origin, sdn, oc")
+	cmd.Flags().StringVar(&o.ForkVersion, "fork-version", o.ForkVersion, "fork version, like 4.2")
+	cmd.Flags().StringVar(&o.KubeVersion, "kube-version", o.KubeVersion, "kube version, like 1.14.1")
+	cmd.Flags().StringVar(&o.Repo, "repo", o.Repo, "only verify this staging repo, like apimachinery, client-go")
+
+	return cmd
+}
+
+func (o *VerifyStagingOptions) Run() error {
+	if len(o.ForkOwner) == 0 {
+		return fmt.Errorf("must have fork-owner")
+	}
+	if len(o.ForkVersion) == 0 {
+		return fmt.Errorf("must have fork-version")
+	}
+	if len(o.KubeVersion) == 0 {
+		return fmt.Errorf("must have kube-version")
+	}
+	forkBranch := kubefork.NewForkBranch(o.ForkOwner, o.ForkVersion, o.KubeVersion)
+
+	repoInfos, err := kubefork.GetAllKubeRepos(o.Streams, o.KubeHome)
+	if err != nil {
+		return err
+	}
+	repoInfos, err = kubefork.ReposForKubeVersion(repoInfos, o.KubeVersion)
+	if err != nil {
+		return err
+	}
+
+	kubeRepo, err := git.PlainOpen(repoInfos[0].Path)
+	if err != nil {
+		return err
+	}
+	kubeTree, err := forkBranchTree(kubeRepo, forkBranch)
+	if err != nil {
+		return fmt.Errorf("kubernetes: %v", err)
+	}
+
+	drifted := []string{}
+	checked := 0
+	for _, currInfo := range repoInfos[1:] {
+		if len(o.Repo) > 0 && currInfo.UpstreamName != o.Repo {
+			continue
+		}
+		checked++
+		if err := kubefork.CloneRepo(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+		if _, _, err := kubefork.FetchUpdates(o.Streams.Indent(), currInfo); err != nil {
+			return err
+		}
+
+		stagingRepo, err := git.PlainOpen(currInfo.Path)
+		if err != nil {
+			return err
+		}
+		stagingTree, err := forkBranchTree(stagingRepo, forkBranch)
+		if err != nil {
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, %v\n", currInfo.UpstreamName, err)
+			drifted = append(drifted, currInfo.UpstreamName)
+			continue
+		}
+		kubeStagingTree, err := kubeTree.Tree(kubefork.StagingPath(currInfo.UpstreamName))
+		if err != nil {
+			return fmt.Errorf("kubernetes %q: %v", kubefork.StagingPath(currInfo.UpstreamName), err)
+		}
+
+		differences, err := DiffTrees(kubeStagingTree, stagingTree, kubefork.PublishingManifests)
+		if err != nil {
+			return err
+		}
+		if len(differences) == 0 {
+			fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, %q matches kubernetes\n", currInfo.UpstreamName, forkBranch.BranchName())
+			continue
+		}
+		drifted = append(drifted, currInfo.UpstreamName)
+		fmt.Fprintf(o.Streams.Out, "For kubernetes/%v, %q differs from kubernetes in %d paths\n", currInfo.UpstreamName, forkBranch.BranchName(), len(differences))
+		for _, difference := range differences {
+			fmt.Fprintf(o.Streams.Indent().Out, "%s\n", difference)
+		}
+	}
+	if len(o.Repo) > 0 && checked == 0 {
+		return fmt.Errorf("%q is not a staging repo in kube %v", o.Repo, o.KubeVersion)
+	}
+
+	if len(drifted) > 0 {
+		return fmt.Errorf("%q has drifted from kubernetes in %v", forkBranch.BranchName(), drifted)
+	}
+	return nil
+}
+
+func forkBranchTree(repo *git.Repository, forkBranch kubefork.ForkBranchInfo) (*object.Tree, error) {
+	ref, err := kubefork.FindOpenShiftBranch(forkBranch.BranchName(), repo)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// DiffTrees lists the files that differ in content or mode between two trees, like "M path" for a modification,
+// "A path" for a file only in the second and "D path" for a file only in the first.
+func DiffTrees(from, to *object.Tree, ignored []string) ([]string, error) {
+	fromFiles, err := treeFiles(from, ignored)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := treeFiles(to, ignored)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for filePath, fromEntry := range fromFiles {
+		toEntry, ok := toFiles[filePath]
+		switch {
+		case !ok:
+			ret = append(ret, "D "+filePath)
+		case fromEntry != toEntry:
+			ret = append(ret, "M "+filePath)
+		}
+	}
+	for filePath := range toFiles {
+		if _, ok := fromFiles[filePath]; !ok {
+			ret = append(ret, "A "+filePath)
+		}
+	}
+
+	// sort by path, not by the kind of difference
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i][2:] < ret[j][2:]
+	})
+	return ret, nil
+}
+
+type treeFile struct {
+	hash plumbing.Hash
+	mode string
+}
+
+func treeFiles(tree *object.Tree, ignored []string) (map[string]treeFile, error) {
+	ignoredPaths := map[string]bool{}
+	for _, ignoredPath := range ignored {
+		ignoredPaths[ignoredPath] = true
+	}
+
+	ret := map[string]treeFile{}
+	err := tree.Files().ForEach(func(file *object.File) error {
+		if ignoredPaths[file.Name] {
+			return nil
+		}
+		ret[file.Name] = treeFile{hash: file.Hash, mode: file.Mode.String()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}